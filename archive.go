@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	plus "google.golang.org/api/plus/v1"
+)
+
+// archiveLocks is how many locks the archive files share, so that finding
+// one user's feed only waits on the few others whose files share its lock.
+const archiveLocks = 64
+
+// ArchiveFeedStorage wraps another FeedStorage and merges every Activity it
+// finds into a per-user JSON file in dir, so posts that scroll off the one
+// page of activities Google+ hands back are kept. Find returns the newest
+// entries activities. FindPages archives everything pager finds.
+type ArchiveFeedStorage struct {
+	store   FeedStorage
+	pager   PagedFeedStorage
+	dir     string
+	entries int
+	lg      *log.Logger
+
+	locks [archiveLocks]sync.Mutex // serialize reads and writes of each archive file
+}
+
+// archivedFeed is the on-disk format of a user's archive. Feed.Items holds
+// every activity ever seen for the user, newest first.
+type archivedFeed struct {
+	Person *plus.Person       `json:"person"`
+	Feed   *plus.ActivityFeed `json:"feed"`
+}
+
+func NewArchiveFeedStorage(fs FeedStorage, pfs PagedFeedStorage, dir string, entries int, lg *log.Logger) (*ArchiveFeedStorage, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &ArchiveFeedStorage{store: fs, pager: pfs, dir: dir, entries: entries, lg: lg}, nil
+}
+
+func (a *ArchiveFeedStorage) Find(userId string) (Feed, error) {
+	feed, err := a.store.Find(userId)
+	if err != nil {
+		return nil, err
+	}
+	af, ok := feed.(*ActorFeed)
+	if !ok {
+		return feed, nil
+	}
+	return a.Merge(af), nil
+}
+
+// FindPages returns every activity the pager found, not just the newest
+// entries, after archiving them.
+func (a *ArchiveFeedStorage) FindPages(userId string, limit PageLimit) (Feed, error) {
+	feed, err := a.pager.FindPages(userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	if af, ok := feed.(*ActorFeed); ok {
+		a.Merge(af)
+	}
+	return feed, nil
+}
+
+// Merge adds the activities in af to the archive of its actor and returns a
+// feed of the newest archived activities. The archive is only written when
+// af has activities that are new, or newly updated. If the archive can't be
+// read or written, af is returned as-is.
+func (a *ArchiveFeedStorage) Merge(af *ActorFeed) *ActorFeed {
+	mu := a.lock(af.actor.Id)
+	mu.Lock()
+	defer mu.Unlock()
+	archiveMerges.Inc(1)
+
+	path := a.path(af.actor.Id)
+	old, err := readArchive(path)
+	if err != nil {
+		archiveFailures.Inc(1)
+		a.lg.Printf("ERROR reading archive %s: %s", path, err)
+		return af
+	}
+	merged := *af.feed
+	var changed bool
+	merged.Items, changed = mergeActivities(old.Feed.Items, af.feed.Items)
+	archivedActivities.Inc(int64(len(merged.Items) - len(old.Feed.Items)))
+
+	if changed || old.Person == nil {
+		err = writeArchive(path, &archivedFeed{af.actor, &merged})
+		if err != nil {
+			archiveFailures.Inc(1)
+			a.lg.Printf("ERROR writing archive %s: %s", path, err)
+			return af
+		}
+	}
+	if a.entries > 0 && len(merged.Items) > a.entries {
+		merged.Items = merged.Items[:a.entries]
+	}
+	return &ActorFeed{af.actor, &merged}
+}
+
+func (a *ArchiveFeedStorage) lock(actorId string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(actorId))
+	return &a.locks[h.Sum32()%archiveLocks]
+}
+
+func (a *ArchiveFeedStorage) path(actorId string) string {
+	return filepath.Join(a.dir, url.PathEscape(actorId)+".json")
+}
+
+func readArchive(path string) (*archivedFeed, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &archivedFeed{Feed: &plus.ActivityFeed{}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	ar := &archivedFeed{}
+	err = json.Unmarshal(b, ar)
+	if err != nil {
+		return nil, err
+	}
+	if ar.Feed == nil {
+		ar.Feed = &plus.ActivityFeed{}
+	}
+	return ar, nil
+}
+
+// writeArchive writes to a temporary file first so that a crash never
+// leaves a half-written archive behind.
+func writeArchive(path string, ar *archivedFeed) error {
+	b, err := json.Marshal(ar)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".archive")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// mergeActivities returns the union of old and fresh, keyed by activity id,
+// sorted newest published first. When both have the same activity, the
+// most recently updated copy wins. changed reports whether fresh had any
+// activity that old didn't, or a more recently updated copy of one.
+func mergeActivities(old, fresh []*plus.Activity) (merged []*plus.Activity, changed bool) {
+	byId := make(map[string]*plus.Activity, len(old)+len(fresh))
+	for _, act := range old {
+		prev, ok := byId[act.Id]
+		if !ok || !parseTime(act.Updated).Before(parseTime(prev.Updated)) {
+			byId[act.Id] = act
+		}
+	}
+	for _, act := range fresh {
+		prev, ok := byId[act.Id]
+		if !ok || parseTime(act.Updated).After(parseTime(prev.Updated)) {
+			changed = true
+		}
+		if !ok || !parseTime(act.Updated).Before(parseTime(prev.Updated)) {
+			byId[act.Id] = act
+		}
+	}
+	merged = make([]*plus.Activity, 0, len(byId))
+	for _, act := range byId {
+		merged = append(merged, act)
+	}
+	sort.Sort(byPublished(merged))
+	return merged, changed
+}
+
+// parseTime parses the RFC 3339 timestamps the Google+ API uses. Unparseable
+// timestamps are treated as the zero time.
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+type byPublished []*plus.Activity
+
+func (b byPublished) Len() int {
+	return len(b)
+}
+
+func (b byPublished) Less(i, j int) bool {
+	return parseTime(b[i].Published).After(parseTime(b[j].Published))
+}
+
+func (b byPublished) Swap(i, j int) {
+	b[i], b[j] = b[j], b[i]
+}
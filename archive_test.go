@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	plus "google.golang.org/api/plus/v1"
+)
+
+// pageStorage is a FeedStorage that returns its pages of activities in
+// order, one per Find.
+type pageStorage struct {
+	pages [][]*plus.Activity
+}
+
+func (s *pageStorage) Find(userId string) (Feed, error) {
+	items := s.pages[0]
+	s.pages = s.pages[1:]
+	return &ActorFeed{&plus.Person{Id: userId}, &plus.ActivityFeed{Items: items}}, nil
+}
+
+func act(id, published, updated string) *plus.Activity {
+	return &plus.Activity{Id: id, Published: published, Updated: updated, Object: &plus.ActivityObject{}}
+}
+
+func TestArchiveKeepsOldActivities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plus2rss-archive")
+	if err != nil {
+		t.Fatalf("TempDir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ps := &pageStorage{[][]*plus.Activity{
+		{
+			act("b", "2012-09-02T00:00:00Z", "2012-09-02T00:00:00Z"),
+			act("a", "2012-09-01T00:00:00Z", "2012-09-01T00:00:00Z"),
+		},
+		{
+			act("c", "2012-09-03T00:00:00Z", "2012-09-03T00:00:00Z"),
+			act("b", "2012-09-02T00:00:00Z", "2012-09-04T00:00:00Z"),
+		},
+	}}
+	as, err := NewArchiveFeedStorage(ps, nil, dir, 2, nullLog())
+	if err != nil {
+		t.Fatalf("NewArchiveFeedStorage: %s", err)
+	}
+	as.Find("1")
+	feed, err := as.Find("1")
+	if err != nil {
+		t.Fatalf("Find: %s", err)
+	}
+	items := feed.Items()
+	if len(items) != 2 || items[0].Id() != "c" || items[1].Id() != "b" {
+		t.Fatalf("want the 2 newest activities, c and b, got %d items", len(items))
+	}
+	if items[1].Updated() != "2012-09-04T00:00:00Z" {
+		t.Errorf("want the most recently updated copy of b, got one updated %s", items[1].Updated())
+	}
+
+	ar, err := readArchive(as.path("1"))
+	if err != nil {
+		t.Fatalf("readArchive: %s", err)
+	}
+	if len(ar.Feed.Items) != 3 {
+		t.Errorf("archive: want 3 activities, got %d", len(ar.Feed.Items))
+	}
+}
+
+func TestArchiveSkipsUnchangedWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plus2rss-archive")
+	if err != nil {
+		t.Fatalf("TempDir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	page := []*plus.Activity{act("a", "2012-09-01T00:00:00Z", "2012-09-01T00:00:00Z")}
+	ps := &pageStorage{[][]*plus.Activity{page, page}}
+	as, err := NewArchiveFeedStorage(ps, nil, dir, 2, nullLog())
+	if err != nil {
+		t.Fatalf("NewArchiveFeedStorage: %s", err)
+	}
+	as.Find("1")
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(as.path("1"), past, past); err != nil {
+		t.Fatalf("Chtimes: %s", err)
+	}
+	feed, err := as.Find("1")
+	if err != nil || len(feed.Items()) != 1 {
+		t.Fatalf("Find: got %v, %v", feed, err)
+	}
+	info, err := os.Stat(as.path("1"))
+	if err != nil {
+		t.Fatalf("Stat: %s", err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Errorf("archive was rewritten without anything new in it")
+	}
+}
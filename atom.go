@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	atomNS  = "http://www.w3.org/2005/Atom"
+	mediaNS = "http://search.yahoo.com/mrss/"
+	thrNS   = "http://purl.org/syndication/thread/1.0"
+)
+
+// atomRenderer is the feedRenderer for Atom (RFC 4287).
+type atomRenderer struct{}
+
+func (atomRenderer) Execute(w io.Writer, data interface{}) error {
+	af, err := NewAtomFeed(data.(*FeedView))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(af)
+}
+
+type AtomFeed struct {
+	XMLName    xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	XMLNSMedia string       `xml:"xmlns:media,attr"`
+	XMLNSThr   string       `xml:"xmlns:thr,attr"`
+	Title      AtomText     `xml:"title"`
+	Id         string       `xml:"id"`
+	Updated    string       `xml:"updated"`
+	Links      []AtomLink   `xml:"link"`
+	Author     AtomPerson   `xml:"author"`
+	Entries    []*AtomEntry `xml:"entry"`
+}
+
+type AtomEntry struct {
+	Title        AtomText         `xml:"title"`
+	Id           string           `xml:"id"`
+	Updated      string           `xml:"updated"`
+	Published    string           `xml:"published,omitempty"`
+	Author       AtomPerson       `xml:"author"`
+	Contributors []AtomPerson     `xml:"contributor"`
+	Summary      *AtomText        `xml:"summary"`
+	Content      AtomText         `xml:"content"`
+	Links        []AtomLink       `xml:"link"`
+	Categories   []AtomCategory   `xml:"category"`
+	Contents     []MediaContent   `xml:"media:content"`
+	Thumbnails   []MediaThumbnail `xml:"media:thumbnail"`
+	InReplyTo    *ThrInReplyTo    `xml:"thr:in-reply-to"`
+	Total        *int64           `xml:"thr:total"`
+}
+
+// AtomText is an Atom text construct. Type is "text" or "html".
+type AtomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type AtomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+// AtomLink is an Atom link. Count is the thr:count of a replies link, and
+// is only set on those.
+type AtomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Title  string `xml:"title,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+	Count  string `xml:"thr:count,attr,omitempty"`
+}
+
+// ThrInReplyTo is the Atom threading (RFC 4685) element that points a
+// comment's entry at the entry of the activity it's on. Ref is that
+// entry's id.
+type ThrInReplyTo struct {
+	Ref  string `xml:"ref,attr"`
+	Href string `xml:"href,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type AtomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// MediaThumbnail is a Media RSS thumbnail, which feed readers use to
+// preview an entry.
+type MediaThumbnail struct {
+	URL    string `xml:"url,attr"`
+	Width  int64  `xml:"width,attr,omitempty"`
+	Height int64  `xml:"height,attr,omitempty"`
+}
+
+// MediaContent is a Media RSS media:content, a photo or video file attached
+// to an entry. Width and Height are 0 when they aren't known.
+type MediaContent struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Medium string `xml:"medium,attr,omitempty"`
+	Title  string `xml:"-"`
+	Width  int64  `xml:"width,attr,omitempty"`
+	Height int64  `xml:"height,attr,omitempty"`
+}
+
+func NewAtomFeed(fv *FeedView) (*AtomFeed, error) {
+	af := &AtomFeed{
+		XMLNSMedia: mediaNS,
+		XMLNSThr:   thrNS,
+		Title:      AtomText{Body: fv.Title()},
+		Id:         atomId(fv.Host, fv.Id()),
+		Updated:    fv.Updated(),
+		Links:      []AtomLink{{Href: fv.AtomURL(), Rel: "self", Type: "application/atom+xml"}},
+		Author:     AtomPerson{Name: fv.ActorName()},
+	}
+	if fv.IsUserFeed() {
+		af.Links = append(af.Links,
+			AtomLink{Href: fv.MetaURL(), Rel: "alternate", Type: "text/html"},
+			AtomLink{Href: fv.RSSURL(), Rel: "alternate", Type: "application/rss+xml"},
+			AtomLink{Href: fv.JSONFeedURL(), Rel: "alternate", Type: "application/feed+json"},
+		)
+		af.Author.URI = "https://plus.google.com/" + fv.ActorId()
+	}
+	for _, iv := range fv.ItemViews() {
+		ae, err := newAtomEntry(iv)
+		if err != nil {
+			return nil, err
+		}
+		af.Entries = append(af.Entries, ae)
+	}
+	return af, nil
+}
+
+func newAtomEntry(iv *ItemView) (*AtomEntry, error) {
+	content, err := iv.Description()
+	if err != nil {
+		return nil, err
+	}
+	ae := &AtomEntry{
+		Title:      AtomText{Body: iv.Title()},
+		Id:         iv.URL(),
+		Updated:    iv.Updated(),
+		Published:  iv.Published(),
+		Author:     AtomPerson{iv.ActorName(), iv.ActorURL()},
+		Content:    AtomText{Type: "html", Body: content},
+		Links:      []AtomLink{{Href: iv.URL(), Rel: "alternate", Type: "text/html"}},
+		Categories: []AtomCategory{{iv.Verb()}},
+	}
+	if text := iv.ContentText(); text != "" {
+		ae.Summary = &AtomText{Type: "text", Body: text}
+	}
+	if ref := iv.InReplyTo(); ref != "" {
+		// Activities' entries use their URL as their id.
+		ae.InReplyTo = &ThrInReplyTo{Ref: ref, Href: ref, Type: "text/html"}
+	} else if iv.RepliesURL() != "" {
+		// Link every activity to the feed of its comments, so that readers
+		// can subscribe to the thread.
+		total := iv.Replies()
+		ae.Links = append(ae.Links, AtomLink{Href: iv.RepliesURL(), Rel: "replies", Type: "application/atom+xml", Count: strconv.FormatInt(total, 10)})
+		ae.Total = &total
+	}
+	if iv.IsReshare() {
+		// The original author of a reshare contributed its content.
+		ae.Contributors = []AtomPerson{{iv.OriginalActorName(), iv.OriginalActorURL()}}
+	}
+	if iv.MediaRSS() {
+		ae.Contents = iv.MediaContents()
+	} else {
+		for _, mc := range iv.MediaContents() {
+			ae.Links = append(ae.Links, AtomLink{Href: mc.URL, Rel: "enclosure", Type: mc.Type, Title: mc.Title})
+		}
+	}
+	for _, a := range iv.Attachments() {
+		switch {
+		case a.IsPhoto():
+			ae.addThumbnail(a.Image())
+		case a.IsVideo():
+			v := NewVideo(a)
+			if v.FileURL == "" && v.WatchURL != "" {
+				ae.Links = append(ae.Links, AtomLink{Href: v.WatchURL, Rel: "related", Type: "text/html", Title: a.DisplayName()})
+			}
+			if i := a.Image(); i != nil {
+				ae.addThumbnail(i)
+			} else if v.Poster != "" {
+				ae.Thumbnails = append(ae.Thumbnails, MediaThumbnail{URL: v.Poster})
+			}
+		case a.IsAlbum():
+			ae.addRelated(a)
+			for _, t := range a.Thumbnails() {
+				ae.addThumbnail(t.Image())
+			}
+		case a.IsArticle(), a.IsEvent(), a.IsAudio():
+			ae.addRelated(a)
+		}
+	}
+	return ae, nil
+}
+
+// addRelated links to the attachment's page, if it has one.
+func (ae *AtomEntry) addRelated(a Attachment) {
+	if a.URL() == "" {
+		return
+	}
+	ae.Links = append(ae.Links, AtomLink{Href: a.URL(), Rel: "related", Title: a.DisplayName()})
+}
+
+// addThumbnail adds i to the entry's thumbnails, unless it's nil.
+func (ae *AtomEntry) addThumbnail(i Image) {
+	if i == nil {
+		return
+	}
+	ae.Thumbnails = append(ae.Thumbnails, MediaThumbnail{i.URL(), i.Width(), i.Height()})
+}
+
+// atomId turns a Feed's id into the absolute IRI Atom requires, a tag URI
+// (RFC 4151) minted under the frontend's host.
+func atomId(host, id string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return "tag:" + strings.ToLower(host) + ",2012:" + id
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	html "html/template"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	plus "google.golang.org/api/plus/v1"
+)
+
+// atomDoc is just enough of Atom to check the parts RFC 4287 requires.
+type atomDoc struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	Id      string   `xml:"http://www.w3.org/2005/Atom id"`
+	Title   string   `xml:"http://www.w3.org/2005/Atom title"`
+	Updated string   `xml:"http://www.w3.org/2005/Atom updated"`
+	Author  *struct {
+		Name string `xml:"http://www.w3.org/2005/Atom name"`
+	} `xml:"http://www.w3.org/2005/Atom author"`
+	Entries []struct {
+		Id      string `xml:"http://www.w3.org/2005/Atom id"`
+		Title   string `xml:"http://www.w3.org/2005/Atom title"`
+		Updated string `xml:"http://www.w3.org/2005/Atom updated"`
+		Author  struct {
+			Name string `xml:"http://www.w3.org/2005/Atom name"`
+			URI  string `xml:"http://www.w3.org/2005/Atom uri"`
+		} `xml:"http://www.w3.org/2005/Atom author"`
+		Contributors []struct {
+			Name string `xml:"http://www.w3.org/2005/Atom name"`
+		} `xml:"http://www.w3.org/2005/Atom contributor"`
+		Content struct {
+			Type string `xml:"type,attr"`
+			Body string `xml:",chardata"`
+		} `xml:"http://www.w3.org/2005/Atom content"`
+		Links []struct {
+			Href  string `xml:"href,attr"`
+			Rel   string `xml:"rel,attr"`
+			Type  string `xml:"type,attr"`
+			Count string `xml:"http://purl.org/syndication/thread/1.0 count,attr"`
+		} `xml:"http://www.w3.org/2005/Atom link"`
+		Total     string `xml:"http://purl.org/syndication/thread/1.0 total"`
+		InReplyTo *struct {
+			Ref string `xml:"ref,attr"`
+		} `xml:"http://purl.org/syndication/thread/1.0 in-reply-to"`
+	} `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+func renderAtom(t *testing.T, fv *FeedView) *atomDoc {
+	buf := new(bytes.Buffer)
+	if err := (atomRenderer{}).Execute(buf, fv); err != nil {
+		t.Fatalf("Execute: %s", err)
+	}
+	doc := &atomDoc{}
+	if err := xml.Unmarshal(buf.Bytes(), doc); err != nil {
+		t.Fatalf("Atom didn't parse: %s\n%s", err, buf)
+	}
+	return doc
+}
+
+func isIRI(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != ""
+}
+
+func testFeedView(feed Feed) *FeedView {
+	return &FeedView{feed, testHost, "", FeedOptions{}, html.Must(html.ParseFiles("./templates/entry.template.html"))}
+}
+
+func TestAtomRequiredElements(t *testing.T) {
+	feed, err := fixtureRetriever(t).Find("116810148281701144465")
+	if err != nil {
+		t.Fatalf("Find: %s", err)
+	}
+	doc := renderAtom(t, testFeedView(feed))
+	if doc.Id != "tag:example.com,2012:plus2rss-116810148281701144465" {
+		t.Errorf("feed id: got %q", doc.Id)
+	}
+	if doc.Title == "" || doc.Updated == "" || doc.Author == nil || doc.Author.Name == "" {
+		t.Errorf("feed missing title, updated, or author: %+v", doc)
+	}
+	if len(doc.Entries) != 20 {
+		t.Fatalf("want 20 entries, got %d", len(doc.Entries))
+	}
+	for i, e := range doc.Entries {
+		if !isIRI(e.Id) || e.Updated == "" || e.Author.Name == "" || !isIRI(e.Author.URI) {
+			t.Errorf("entry %d missing id, updated, or author: %+v", i, e)
+		}
+		if e.Content.Type != "html" {
+			t.Errorf("entry %d content type: got %q", i, e.Content.Type)
+		}
+	}
+
+	// The Google+ content is HTML, and should come out of the parser as
+	// HTML, not as escaped HTML.
+	if c := doc.Entries[0].Content.Body; !strings.Contains(c, "<br />") {
+		t.Errorf("entry content was double escaped: %q", c)
+	}
+	var enclosures int
+	for _, l := range doc.Entries[7].Links {
+		if l.Rel == "enclosure" {
+			enclosures++
+			if l.Type != "image/jpeg" || !isIRI(l.Href) {
+				t.Errorf("bad enclosure: %+v", l)
+			}
+		}
+	}
+	if enclosures != 1 {
+		t.Errorf("want 1 enclosure, got %d", enclosures)
+	}
+}
+
+func TestAtomEmptyFeedUpdated(t *testing.T) {
+	feed := &ActorFeed{&plus.Person{Id: "1", DisplayName: "Quiet User"}, &plus.ActivityFeed{}}
+	doc := renderAtom(t, testFeedView(feed))
+	if _, err := time.Parse(time.RFC3339, doc.Updated); err != nil {
+		t.Errorf("want a feed without posts to have a valid updated, got %q: %s", doc.Updated, err)
+	}
+}
+
+func TestAtomControlCharacters(t *testing.T) {
+	a := &plus.Activity{
+		Id:      "z1",
+		Url:     "https://plus.google.com/1/posts/z1",
+		Title:   "bell\x07",
+		Actor:   &plus.ActivityActor{DisplayName: "Someone\x00", Url: "https://plus.google.com/1"},
+		Object:  &plus.ActivityObject{Content: "form\x0cfeed"},
+		Updated: "2012-09-26T22:12:46.927Z",
+	}
+	feed := &ActorFeed{&plus.Person{Id: "1"}, &plus.ActivityFeed{Items: []*plus.Activity{a}}}
+	doc := renderAtom(t, testFeedView(feed))
+	if len(doc.Entries) != 1 || strings.ContainsAny(doc.Entries[0].Title, "\x07") {
+		t.Errorf("control character survived: %+v", doc.Entries)
+	}
+}
+
+func TestAtomRepliesLinks(t *testing.T) {
+	feed, err := fixtureRetriever(t).Find("116810148281701144465")
+	if err != nil {
+		t.Fatalf("Find: %s", err)
+	}
+	doc := renderAtom(t, testFeedView(feed))
+	e := doc.Entries[0]
+	if e.Total != "10" {
+		t.Errorf("thr:total: want 10, got %q", e.Total)
+	}
+	var found bool
+	for _, l := range e.Links {
+		if l.Rel != "replies" {
+			continue
+		}
+		found = true
+		if l.Href != "http://"+testHost+"/c/"+testActivityId || l.Type != "application/atom+xml" || l.Count != "10" {
+			t.Errorf("replies link: got %+v", l)
+		}
+	}
+	if !found {
+		t.Errorf("no replies link on %s", e.Id)
+	}
+}
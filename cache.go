@@ -0,0 +1,148 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// CachingFeedStorage wraps another FeedStorage and keeps the Feeds it finds
+// in memory for ttl. When more than maxEntries Feeds are held, the least
+// recently used is evicted. Errors are never cached.
+type CachingFeedStorage struct {
+	store FeedStorage
+	cache *ttlCache
+}
+
+func NewCachingFeedStorage(fs FeedStorage, ttl time.Duration, maxEntries int) *CachingFeedStorage {
+	return &CachingFeedStorage{fs, newTTLCache(ttl, maxEntries, cacheEvictions)}
+}
+
+func (c *CachingFeedStorage) Find(userId string) (Feed, error) {
+	if v, ok := c.cache.Get(userId); ok {
+		cacheHits.Inc(1)
+		return v.(Feed), nil
+	}
+	cacheMisses.Inc(1)
+	feed, err := c.store.Find(userId)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Add(userId, feed)
+	return feed, nil
+}
+
+// Refresh finds the user's feed in the wrapped FeedStorage and caches it,
+// whether or not a copy was already cached.
+func (c *CachingFeedStorage) Refresh(userId string) (Feed, error) {
+	feed, err := c.store.Find(userId)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Add(userId, feed)
+	return feed, nil
+}
+
+// ttlCache is a size-bounded LRU whose entries also expire after a fixed
+// duration. It is safe for concurrent use.
+type ttlCache struct {
+	ttl        time.Duration
+	maxEntries int
+	evictions  metrics.Counter
+	now        func() time.Time
+
+	mu      sync.Mutex
+	ll      *list.List
+	entries map[string]*list.Element
+}
+
+type ttlEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+func newTTLCache(ttl time.Duration, maxEntries int, evictions metrics.Counter) *ttlCache {
+	return &ttlCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		evictions:  evictions,
+		now:        time.Now,
+		ll:         list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (c *ttlCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	ent := el.Value.(*ttlEntry)
+	if !c.now().Before(ent.expires) {
+		c.removeElement(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return ent.value, true
+}
+
+// Peek is Get without marking the entry as recently used, for looking at
+// entries without keeping them from being evicted.
+func (c *ttlCache) Peek(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	ent := el.Value.(*ttlEntry)
+	if !c.now().Before(ent.expires) {
+		return nil, false
+	}
+	return ent.value, true
+}
+
+func (c *ttlCache) Add(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	if el, ok := c.entries[key]; ok {
+		ent := el.Value.(*ttlEntry)
+		ent.value = value
+		ent.expires = expires
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.ll.PushFront(&ttlEntry{key, value, expires})
+	for c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+		c.evictions.Inc(1)
+	}
+}
+
+func (c *ttlCache) Remove(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	c.removeElement(el)
+	return true
+}
+
+func (c *ttlCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *ttlCache) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.entries, el.Value.(*ttlEntry).key)
+}
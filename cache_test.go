@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	plus "google.golang.org/api/plus/v1"
+)
+
+// countingStorage is a FeedStorage that records how many times each user id
+// was asked for and returns err if it is set.
+type countingStorage struct {
+	mu    sync.Mutex
+	calls map[string]int
+	err   error
+}
+
+func (s *countingStorage) Find(userId string) (Feed, error) {
+	s.mu.Lock()
+	if s.calls == nil {
+		s.calls = make(map[string]int)
+	}
+	s.calls[userId]++
+	err := s.err
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return fakeFeed(userId), nil
+}
+
+func (s *countingStorage) Calls(userId string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[userId]
+}
+
+func fakeFeed(userId string) Feed {
+	return &ActorFeed{&plus.Person{Id: userId}, &plus.ActivityFeed{}}
+}
+
+func TestCacheHitsAndExpiry(t *testing.T) {
+	cs := &countingStorage{}
+	c := NewCachingFeedStorage(cs, time.Minute, 10)
+	now := time.Now()
+	c.cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		feed, err := c.Find("1")
+		if err != nil {
+			t.Fatalf("Find: %s", err)
+		}
+		if feed.ActorId() != "1" {
+			t.Errorf("ActorId: want %q, got %q", "1", feed.ActorId())
+		}
+	}
+	if cs.Calls("1") != 1 {
+		t.Errorf("before expiry, want 1 upstream call, got %d", cs.Calls("1"))
+	}
+
+	now = now.Add(time.Minute)
+	c.Find("1")
+	if cs.Calls("1") != 2 {
+		t.Errorf("after expiry, want 2 upstream calls, got %d", cs.Calls("1"))
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cs := &countingStorage{}
+	c := NewCachingFeedStorage(cs, time.Hour, 2)
+	evictions := metrics.NewCounter()
+	c.cache.evictions = evictions
+
+	c.Find("1")
+	c.Find("2")
+	c.Find("1")
+	c.Find("3")
+	if evictions.Count() != 1 {
+		t.Errorf("evictions: want 1, got %d", evictions.Count())
+	}
+	c.Find("1")
+	if cs.Calls("1") != 1 {
+		t.Errorf("recently used entry was evicted")
+	}
+	c.Find("2")
+	if cs.Calls("2") != 2 {
+		t.Errorf("least recently used entry was not evicted")
+	}
+}
+
+func TestCacheDoesNotStoreErrors(t *testing.T) {
+	cs := &countingStorage{err: errors.New("boom")}
+	c := NewCachingFeedStorage(cs, time.Hour, 2)
+	c.Find("1")
+	c.Find("1")
+	if cs.Calls("1") != 2 {
+		t.Errorf("want 2 upstream calls, got %d", cs.Calls("1"))
+	}
+}
+
+func TestCachePeekLeavesOrder(t *testing.T) {
+	c := newTTLCache(time.Hour, 2, metrics.NewCounter())
+	c.Add("1", 1)
+	c.Add("2", 2)
+	if v, ok := c.Peek("1"); !ok || v != 1 {
+		t.Errorf("Peek: got %v, %t", v, ok)
+	}
+	c.Add("3", 3)
+	if _, ok := c.Peek("1"); ok {
+		t.Errorf("peeked entry wasn't evicted as least recently used")
+	}
+	if _, ok := c.Peek("2"); !ok {
+		t.Errorf("entry used after the peeked one was evicted")
+	}
+}
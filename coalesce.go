@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sync"
+)
+
+// CoalescingFeedStorage wraps another FeedStorage so that concurrent Finds
+// for the same user id share a single call to it, and its result.
+type CoalescingFeedStorage struct {
+	store FeedStorage
+
+	mu    sync.Mutex
+	calls map[string]*findCall
+}
+
+type findCall struct {
+	wg   sync.WaitGroup
+	feed Feed
+	err  error
+}
+
+func NewCoalescingFeedStorage(fs FeedStorage) *CoalescingFeedStorage {
+	return &CoalescingFeedStorage{store: fs, calls: make(map[string]*findCall)}
+}
+
+func (c *CoalescingFeedStorage) Find(userId string) (Feed, error) {
+	c.mu.Lock()
+	if call, ok := c.calls[userId]; ok {
+		c.mu.Unlock()
+		coalescedFinds.Inc(1)
+		call.wg.Wait()
+		return call.feed, call.err
+	}
+	call := &findCall{}
+	call.wg.Add(1)
+	c.calls[userId] = call
+	c.mu.Unlock()
+
+	coalescedUpstreamFinds.Inc(1)
+	call.feed, call.err = c.store.Find(userId)
+	call.wg.Done()
+
+	c.mu.Lock()
+	delete(c.calls, userId)
+	c.mu.Unlock()
+	return call.feed, call.err
+}
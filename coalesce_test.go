@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// blockingStorage is a FeedStorage whose Finds wait on release before
+// returning.
+type blockingStorage struct {
+	countingStorage
+	started chan bool
+	release chan bool
+}
+
+func (s *blockingStorage) Find(userId string) (Feed, error) {
+	s.started <- true
+	<-s.release
+	return s.countingStorage.Find(userId)
+}
+
+func TestCoalescingSharesInFlightFind(t *testing.T) {
+	bs := &blockingStorage{started: make(chan bool, 10), release: make(chan bool)}
+	bs.err = errors.New("boom")
+	c := NewCoalescingFeedStorage(bs)
+
+	before := coalescedFinds.Count()
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	wg.Add(1)
+	go func() {
+		_, errs[0] = c.Find("1")
+		wg.Done()
+	}()
+	<-bs.started
+	for i := 1; i < len(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			_, errs[i] = c.Find("1")
+			wg.Done()
+		}(i)
+	}
+	for coalescedFinds.Count()-before != int64(len(errs)-1) {
+		// Wait until every other Find has joined the in-flight call.
+		runtime.Gosched()
+	}
+	close(bs.release)
+	wg.Wait()
+
+	if bs.Calls("1") != 1 {
+		t.Errorf("upstream calls: want 1, got %d", bs.Calls("1"))
+	}
+	for i, err := range errs {
+		if err != bs.err {
+			t.Errorf("%d: want shared error %v, got %v", i, bs.err, err)
+		}
+	}
+
+	c.Find("1")
+	if bs.Calls("1") != 2 {
+		t.Errorf("finished call was reused; upstream calls: want 2, got %d", bs.Calls("1"))
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"regexp"
+	"time"
+
+	plus "google.golang.org/api/plus/v1"
+)
+
+// activityIdR is what a Google+ activity's id looks like.
+var activityIdR = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// CommentStorage finds the feed of the comments on an activity.
+type CommentStorage interface {
+	FindComments(activityId string) (Feed, error)
+}
+
+// FindComments finds the activity and its newest comments, newest first.
+func (f *FeedRetriever) FindComments(activityId string) (Feed, error) {
+	commentFinds.Inc(1)
+	feed, err := f.findComments(activityId)
+	if err != nil {
+		commentFindFailures.Inc(1)
+	}
+	return feed, err
+}
+
+func (f *FeedRetriever) findComments(activityId string) (Feed, error) {
+	ch := make(chan error)
+	var act *plus.Activity
+	go func() {
+		var aErr error
+		f.lg.Printf("Activity: %s", activityId)
+		act, aErr = f.client.Activities.Get(activityId).Do()
+		ch <- aErr
+	}()
+
+	f.lg.Printf("List Comments of Activity: %s", activityId)
+	comments, err := f.client.Comments.List(activityId).SortOrder("descending").MaxResults(100).Do()
+	aErr := <-ch
+	if err != nil {
+		return nil, err
+	}
+	if aErr != nil {
+		return nil, aErr
+	}
+	return &CommentsFeed{act, comments}, nil
+}
+
+// CachingCommentStorage wraps another CommentStorage and keeps the comment
+// feeds it finds in memory for ttl, like CachingFeedStorage does for users.
+type CachingCommentStorage struct {
+	store CommentStorage
+	cache *ttlCache
+}
+
+func NewCachingCommentStorage(cs CommentStorage, ttl time.Duration, maxEntries int) *CachingCommentStorage {
+	return &CachingCommentStorage{cs, newTTLCache(ttl, maxEntries, commentCacheEvictions)}
+}
+
+func (c *CachingCommentStorage) FindComments(activityId string) (Feed, error) {
+	if v, ok := c.cache.Get(activityId); ok {
+		commentCacheHits.Inc(1)
+		return v.(Feed), nil
+	}
+	commentCacheMisses.Inc(1)
+	feed, err := c.store.FindComments(activityId)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Add(activityId, feed)
+	return feed, nil
+}
+
+// CommentsFeed is the Feed of the comments on one activity. Its ActorName
+// is the activity's author and its ActorId is the activity's id.
+type CommentsFeed struct {
+	activity *plus.Activity
+	comments *plus.CommentFeed
+}
+
+func (c *CommentsFeed) Title() string {
+	return c.ActorName()
+}
+
+func (c *CommentsFeed) Id() string {
+	return "plus2rss-c-" + c.activity.Id
+}
+
+// Updated is when the newest comment was made, or, without any, when the
+// activity was last changed.
+func (c *CommentsFeed) Updated() string {
+	if c.comments.Updated != "" {
+		return c.comments.Updated
+	}
+	return c.activity.Updated
+}
+
+func (c *CommentsFeed) Items() []Activity {
+	acts := make([]Activity, len(c.comments.Items))
+	for i, cm := range c.comments.Items {
+		acts[i] = &JSONComment{*cm, c.activity.Url}
+	}
+	return acts
+}
+
+func (c *CommentsFeed) ActorName() string {
+	return "Comments on a post by " + c.activity.Actor.DisplayName
+}
+
+func (c *CommentsFeed) ActorId() string {
+	return c.activity.Id
+}
+
+// JSONComment is a comment seen as an Activity, so that it can be rendered
+// like one. Comments have no page of their own, so a comment's URL is its
+// activity's with the comment's id as the fragment.
+type JSONComment struct {
+	pC          plus.Comment
+	activityURL string
+}
+
+func (c *JSONComment) Verb() string {
+	return "post"
+}
+
+func (c *JSONComment) Updated() string {
+	return c.pC.Updated
+}
+
+func (c *JSONComment) Published() string {
+	return c.pC.Published
+}
+
+func (c *JSONComment) Content() string {
+	return c.pC.Object.Content
+}
+
+func (c *JSONComment) ContentHTML() string {
+	return sanitizeHTML(c.pC.Object.Content)
+}
+
+func (c *JSONComment) ContentText() string {
+	return htmlText(c.pC.Object.Content)
+}
+
+// Title is always empty, since comments don't have one.
+func (c *JSONComment) Title() string {
+	return ""
+}
+
+func (c *JSONComment) Id() string {
+	return c.pC.Id
+}
+
+func (c *JSONComment) URL() string {
+	return c.activityURL + "#" + c.pC.Id
+}
+
+func (c *JSONComment) ActorName() string {
+	return c.pC.Actor.DisplayName
+}
+
+func (c *JSONComment) ActorURL() string {
+	return c.pC.Actor.Url
+}
+
+func (c *JSONComment) Attachments() []Attachment {
+	return nil
+}
+
+// Replies is always 0, since comments can't be replied to.
+func (c *JSONComment) Replies() int64 {
+	return 0
+}
+
+// CommentsId is always empty, since comments can't be replied to.
+func (c *JSONComment) CommentsId() string {
+	return ""
+}
+
+// InReplyTo is the URL of the activity the comment is on.
+func (c *JSONComment) InReplyTo() string {
+	return c.activityURL
+}
+
+func (c *JSONComment) IsReshare() bool {
+	return false
+}
+
+func (c *JSONComment) Annotation() string {
+	return ""
+}
+
+func (c *JSONComment) OriginalActorName() string {
+	return ""
+}
+
+func (c *JSONComment) OriginalActorURL() string {
+	return ""
+}
+
+func (c *JSONComment) OriginalURL() string {
+	return ""
+}
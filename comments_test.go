@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testActivityId = "z13ysb1jwve1dbnev22ld1jolnn5cjx2m"
+
+func TestFindComments(t *testing.T) {
+	feed, err := fixtureRetriever(t).FindComments(testActivityId)
+	if err != nil {
+		t.Fatalf("FindComments: %s", err)
+	}
+	if feed.Id() != "plus2rss-c-"+testActivityId {
+		t.Errorf("Id: got %q", feed.Id())
+	}
+	if feed.ActorName() != "Comments on a post by Russ Cox" {
+		t.Errorf("ActorName: got %q", feed.ActorName())
+	}
+	if feed.Updated() != "2012-09-27T01:30:12.345Z" {
+		t.Errorf("Updated: got %q", feed.Updated())
+	}
+	items := feed.Items()
+	if len(items) != 2 {
+		t.Fatalf("want 2 comments, got %d", len(items))
+	}
+	c := items[0]
+	if c.ActorName() != "Rob Pike" {
+		t.Errorf("ActorName: got %q", c.ActorName())
+	}
+	postURL := "https://plus.google.com/116810148281701144465/posts/GKfM9zWMXYp"
+	if c.URL() != postURL+"#"+c.Id() || c.InReplyTo() != postURL {
+		t.Errorf("URL %q and InReplyTo %q should be the post's", c.URL(), c.InReplyTo())
+	}
+	if strings.Contains(items[1].ContentHTML(), "script") {
+		t.Errorf("comment content wasn't sanitized: %q", items[1].ContentHTML())
+	}
+}
+
+func TestFindCommentsNotFound(t *testing.T) {
+	_, err := fixtureRetriever(t).FindComments("nosuchactivity")
+	if !isNotFound(err) {
+		t.Errorf("want a not found error, got %v", err)
+	}
+}
+
+func TestCachingCommentStorage(t *testing.T) {
+	c := NewCachingCommentStorage(fixtureRetriever(t), time.Minute, 10)
+	first, err := c.FindComments(testActivityId)
+	if err != nil {
+		t.Fatalf("FindComments: %s", err)
+	}
+	second, err := c.FindComments(testActivityId)
+	if err != nil {
+		t.Fatalf("FindComments: %s", err)
+	}
+	if first != second {
+		t.Errorf("second find wasn't served from the cache")
+	}
+	if _, err := c.FindComments("nosuchactivity"); err == nil {
+		t.Errorf("missing activity: want an error")
+	}
+	if c.cache.Len() != 1 {
+		t.Errorf("errors shouldn't be cached, but %d entries are", c.cache.Len())
+	}
+}
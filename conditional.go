@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// notModified sets the ETag and Last-Modified headers for the feed as it
+// would be rendered for r. If r's If-None-Match or If-Modified-Since say the
+// client already has that rendering, it writes a 304 and returns true, and
+// the feed needn't be rendered at all.
+func notModified(w http.ResponseWriter, r *http.Request, feed Feed) bool {
+	etag := feedETag(r, feed)
+	w.Header().Set("ETag", etag)
+	lastMod := parseTime(feed.Updated())
+	if !lastMod.IsZero() {
+		w.Header().Set("Last-Modified", lastMod.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, etag) {
+			return false
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastMod.IsZero() {
+		t, err := http.ParseTime(ims)
+		if err != nil || lastMod.Truncate(time.Second).After(t) {
+			return false
+		}
+	} else {
+		return false
+	}
+
+	notModifiedResponses.Inc(1)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// feedETag is a weak ETag that changes whenever the feed gains or changes a
+// post, whenever the request asks for a different rendering of it, and
+// whenever the server restarts with possibly different templates.
+func feedETag(r *http.Request, feed Feed) string {
+	h := sha1.New()
+	io.WriteString(h, bootTime.String())
+	io.WriteString(h, "\x00"+r.Host+r.URL.Path+"?"+r.URL.RawQuery)
+	io.WriteString(h, "\x00"+feed.Id()+"\x00"+feed.ActorName()+"\x00"+feed.Updated())
+	items := feed.Items()
+	var newest time.Time
+	for _, a := range items {
+		if u := parseTime(a.Updated()); u.After(newest) {
+			newest = u
+		}
+	}
+	io.WriteString(h, "\x00"+newest.String()+"\x00"+strconv.Itoa(len(items)))
+	return `W/"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header value inm matches
+// etag, using the weak comparison that RFC 7232 requires for If-None-Match.
+func etagMatches(inm, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(inm, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
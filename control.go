@@ -12,6 +12,8 @@ import (
 
 const (
 	vars = `{{range .}}{{.Name}} {{.Value}}
+{{end}}`
+	stale = `{{range .}}{{.UserId}} {{.Age.Seconds}}
 {{end}}`
 )
 
@@ -19,16 +21,28 @@ var (
 	index = []byte(`<!DOCTYPE html>
 <html>
   <a href="/vars">/vars</a>
+  <a href="/stale">/stale</a>
+  <form action="/not_found/clear" method="post">
+    <input name="user_id">
+    <button type="submit">Forget user was not found</button>
+  </form>
 </html>
 `)
+	bodyCleared    = []byte("cleared\n")
+	bodyNotCleared = []byte("user was not cached as not found\n")
 )
 
-var varsTmpl = template.Must(template.New("vars").Parse(vars))
+var (
+	varsTmpl  = template.Must(template.New("vars").Parse(vars))
+	staleTmpl = template.Must(template.New("stale").Parse(stale))
+)
 
-func NewStatServer(addr string) *http.Server {
+func NewStatServer(addr string, ss *StaleFeedStorage, nfs *NotFoundCachingFeedStorage) *http.Server {
 	d := time.Duration(400 * time.Millisecond)
 	m := http.NewServeMux()
 	m.Handle("/vars", &StatHandler{registry})
+	m.Handle("/stale", &StaleHandler{ss})
+	m.Handle("/not_found/clear", &ClearNotFoundHandler{nfs})
 	m.Handle("/", http.HandlerFunc(ControlIndexHandler))
 	return &http.Server{Addr: addr, Handler: m, ReadTimeout: d, WriteTimeout: d}
 }
@@ -70,6 +84,8 @@ func (s *StatHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		switch v := obj.(type) {
 		case metrics.Counter:
 			stats = append(stats, Stat{s, strconv.FormatInt(v.Count(), 10)})
+		case metrics.Gauge:
+			stats = append(stats, Stat{s, strconv.FormatInt(v.Value(), 10)})
 		case metrics.Timer:
 			fifteen := strconv.FormatFloat(v.Rate15(), 'g', -1, 64)
 			stats = append(stats, Stat{s + "_fifteen_minute_rate", fifteen})
@@ -88,7 +104,7 @@ func (s *StatHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			p9999 := strconv.FormatInt(int64(v.Percentile(0.9999)), 10)
 			stats = append(stats, Stat{s + "_p9999", p9999})
 		default:
-			// TODO(jmhodges): Gauges, Meters, Histograms, Samples
+			// TODO(jmhodges): Meters, Histograms, Samples
 		}
 	})
 	sort.Sort(statSlice(stats))
@@ -99,3 +115,40 @@ func (s *StatHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		log.Printf("ERROR unable to execute /vars template: %v", err)
 	}
 }
+
+// StaleHandler lists the user ids whose feeds are being served stale and how
+// many seconds old those feeds are, oldest first.
+type StaleHandler struct {
+	ss *StaleFeedStorage
+}
+
+func (s *StaleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "plain/text; charset=utf8")
+	w.WriteHeader(http.StatusOK)
+	err := staleTmpl.Execute(w, s.ss.StaleAges())
+	if err != nil {
+		log.Printf("ERROR unable to execute /stale template: %v", err)
+	}
+}
+
+// ClearNotFoundHandler makes the frontend forget that the user_id given in a
+// POST was not found, so the next request for it goes to Google+.
+type ClearNotFoundHandler struct {
+	nfs *NotFoundCachingFeedStorage
+}
+
+func (c *ClearNotFoundHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Add("Content-Type", "plain/text; charset=utf8")
+	if !c.nfs.Clear(r.FormValue("user_id")) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(bodyNotCleared)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(bodyCleared)
+}
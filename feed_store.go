@@ -2,7 +2,10 @@ package main
 
 import (
 	"log"
+	"net/http"
+	"time"
 
+	"google.golang.org/api/googleapi"
 	plus "google.golang.org/api/plus/v1"
 )
 
@@ -15,6 +18,38 @@ type FeedStorage interface {
 	Find(string) (Feed, error)
 }
 
+// Backend is where feeds come from in the end: the Google+ API, by way of a
+// FeedRetriever, or a TakeoutStorage.
+type Backend interface {
+	FeedStorage
+	PagedFeedStorage
+	SearchStorage
+	CommentStorage
+}
+
+// PagedFeedStorage finds more of a user's history than the first page of
+// activities Google+ returns.
+type PagedFeedStorage interface {
+	FindPages(string, PageLimit) (Feed, error)
+}
+
+// PageLimit bounds how far back FindPages walks a user's activities. At least
+// one page is always fetched. A zero Since means no time limit.
+type PageLimit struct {
+	Pages int
+	Since time.Time
+}
+
+// ParseSince parses a PageLimit.Since given as either an RFC 3339 timestamp
+// or a plain date.
+func ParseSince(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", s)
+	}
+	return t, err
+}
+
 type Feed interface {
 	Title() string
 	Id() string
@@ -24,7 +59,6 @@ type Feed interface {
 	ActorId() string
 }
 
-// TODO An obvious place to cache data.
 func (f *FeedRetriever) Find(userId string) (Feed, error) {
 	findAttempts.Inc(1)
 	var feed Feed
@@ -47,7 +81,7 @@ func (f *FeedRetriever) find(userId string) (Feed, error) {
 		ch <- pErr
 	}()
 
-	feed, err := f.retrieveActivities(userId)
+	feed, err := f.retrieveActivities(userId, "")
 	if err != nil {
 		return nil, err
 	}
@@ -59,12 +93,96 @@ func (f *FeedRetriever) find(userId string) (Feed, error) {
 	return &ActorFeed{actor, feed}, nil
 }
 
+func (f *FeedRetriever) FindPages(userId string, limit PageLimit) (Feed, error) {
+	findAttempts.Inc(1)
+	var feed Feed
+	var err error
+	findTimer.Time(func() { feed, err = f.findPages(userId, limit) })
+	if err == nil {
+		findSuccesses.Inc(1)
+	} else {
+		findFailures.Inc(1)
+	}
+	return feed, err
+}
+
+func (f *FeedRetriever) findPages(userId string, limit PageLimit) (Feed, error) {
+	ch := make(chan error)
+	var actor *plus.Person
+	go func() {
+		var pErr error
+		actor, pErr = f.retrievePerson(userId)
+		ch <- pErr
+	}()
+
+	var feed *plus.ActivityFeed
+	var err error
+	pageToken := ""
+	for i := 0; i == 0 || i < limit.Pages; i++ {
+		var page *plus.ActivityFeed
+		page, err = f.retrieveActivities(userId, pageToken)
+		if err != nil {
+			break
+		}
+		pagesFetched.Inc(1)
+		done := page.NextPageToken == ""
+		if !limit.Since.IsZero() {
+			page.Items, done = itemsSince(page.Items, limit.Since, done)
+		}
+		if feed == nil {
+			feed = page
+		} else {
+			feed.Items = append(feed.Items, page.Items...)
+		}
+		if done {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+
+	pErr := <-ch
+	if err != nil {
+		return nil, err
+	}
+	if pErr != nil {
+		return nil, pErr
+	}
+	return &ActorFeed{actor, feed}, nil
+}
+
+// itemsSince drops the activities published before since and reports whether
+// there is no need to look at older pages.
+func itemsSince(items []*plus.Activity, since time.Time, done bool) ([]*plus.Activity, bool) {
+	kept := items[:0]
+	for _, a := range items {
+		if parseTime(a.Published).Before(since) {
+			done = true
+			continue
+		}
+		kept = append(kept, a)
+	}
+	return kept, done
+}
+
 func (f *FeedRetriever) retrievePerson(userId string) (*plus.Person, error) {
 	f.lg.Printf("Person: %s", userId)
 	return f.client.People.Get(userId).Do()
 }
 
-func (f *FeedRetriever) retrieveActivities(userId string) (*plus.ActivityFeed, error) {
-	f.lg.Printf("List Public Activities of User: %s", userId)
-	return f.client.Activities.List(userId, "public").Do()
+func (f *FeedRetriever) retrieveActivities(userId, pageToken string) (*plus.ActivityFeed, error) {
+	call := f.client.Activities.List(userId, "public")
+	if pageToken == "" {
+		f.lg.Printf("List Public Activities of User: %s", userId)
+	} else {
+		f.lg.Printf("List Public Activities of User: %s, page: %s", userId, pageToken)
+		call = call.PageToken(pageToken)
+	}
+	return call.Do()
+}
+
+// isNotFound reports whether err is the Google+ API saying there is no such
+// user.
+func isNotFound(err error) bool {
+	gerr, ok := err.(*googleapi.Error)
+	return ok && gerr.Code == http.StatusNotFound
 }
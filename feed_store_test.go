@@ -15,6 +15,11 @@ var (
 	feedResp      = mustResponse(ioutil.ReadFile("./testdata/feed.json"))
 	person404Resp = mustResponse(ioutil.ReadFile("./testdata/person_404.json"))
 	feed404Resp   = mustResponse(ioutil.ReadFile("./testdata/feed_404.json"))
+
+	activityResp    = mustResponse(ioutil.ReadFile("./testdata/activity.json"))
+	commentsResp    = mustResponse(ioutil.ReadFile("./testdata/comments.json"))
+	activity404Resp = mustResponse(ioutil.ReadFile("./testdata/activity_404.json"))
+	comments404Resp = mustResponse(ioutil.ReadFile("./testdata/comments_404.json"))
 )
 
 func TestSuccessfulFind(t *testing.T) {
@@ -43,6 +48,30 @@ func TestSuccessfulFind(t *testing.T) {
 	}
 }
 
+func TestFindPagesSince(t *testing.T) {
+	tr := &FakeClientTransport{}
+	tr.Add(personResp.URL, "GET", personResp.Response)
+	tr.Add(feedResp.URL, "GET", feedResp.Response)
+	srv, err := plus.New(&http.Client{Transport: tr})
+	if err != nil {
+		t.Fatalf("unable to make Google+ client: %s", err)
+	}
+	since, err := ParseSince("2012-09-01")
+	if err != nil {
+		t.Fatalf("ParseSince: %s", err)
+	}
+	fr := &FeedRetriever{srv, nullLog()}
+	// Only the first page is known to the transport, so asking for the
+	// second would fail.
+	feed, err := fr.FindPages("116810148281701144465", PageLimit{Pages: 5, Since: since})
+	if err != nil {
+		t.Fatalf("unable to FindPages: %s", err)
+	}
+	if len(feed.Items()) != 4 {
+		t.Errorf("want the 4 posts since %s, got %d", since, len(feed.Items()))
+	}
+}
+
 var resp404Table = []struct {
 	pers *ResponseFixture
 	feed *ResponseFixture
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	plus "google.golang.org/api/plus/v1"
+)
+
+var (
+	fixturePersonR   = regexp.MustCompile(`/people/([^/?]+)(?:\?|$)`)
+	fixtureCommentsR = regexp.MustCompile(`/activities/([^/?]+)/comments`)
+)
+
+// fixtureFile is the start of a fixture file. Recorded responses, as a
+// RecordingTransport writes them, have a URL, Code and Body. Hand-written
+// ones are just the body, which always has a Kind.
+type fixtureFile struct {
+	Kind string `json:"kind"`
+	URL  string `json:"url"`
+	Code int    `json:"code"`
+	Body string `json:"body"`
+}
+
+// FixtureStorage serves feeds out of a directory of Google+ API responses,
+// for running without Google+. Each JSON file in it is either a response
+// recorded by RecordingTransport or a hand-written API response: a person,
+// a page of a user's activities, a single activity, or the comments on an
+// activity, told apart by their "kind". Recorded responses that weren't a
+// 200 are skipped. Activities must have an object, and belong to the user
+// who's their actor. Comments belong to the activity in their recorded URL
+// or their feed's id. A user with activities but no person file is made up
+// from the activities.
+//
+// Watch reloads the directory when its files change. Until a reload
+// succeeds, the feeds already loaded are kept.
+type FixtureStorage struct {
+	dir string
+	lg  *log.Logger
+
+	mu    sync.RWMutex
+	index *postIndex
+	stamp string
+}
+
+// LoadFixtures reads the fixtures in dir.
+func LoadFixtures(dir string, lg *log.Logger) (*FixtureStorage, error) {
+	fs := &FixtureStorage{dir: dir, lg: lg}
+	_, err := fs.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// Watch checks dir for changes every interval, and reloads it when there
+// are any. It never returns.
+func (fs *FixtureStorage) Watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		reloaded, err := fs.Reload()
+		if err != nil {
+			fixtureReloadFailures.Inc(1)
+			fs.lg.Printf("Fixtures: reloading %s failed, keeping the old ones: %s", fs.dir, err)
+		} else if reloaded {
+			fs.lg.Printf("Fixtures: reloaded %s", fs.dir)
+		}
+	}
+}
+
+// Reload reads dir again if its files have changed since it was last read,
+// and reports whether it did.
+func (fs *FixtureStorage) Reload() (bool, error) {
+	stamp, err := fixtureStamp(fs.dir)
+	if err != nil {
+		return false, err
+	}
+	fs.mu.RLock()
+	unchanged := fs.index != nil && stamp == fs.stamp
+	fs.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	idx, err := loadFixtureIndex(fs.dir)
+	if err != nil {
+		return false, err
+	}
+	fs.mu.Lock()
+	fs.index, fs.stamp = idx, stamp
+	fs.mu.Unlock()
+	fixtureReloads.Inc(1)
+	return true, nil
+}
+
+func (fs *FixtureStorage) current() *postIndex {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.index
+}
+
+func (fs *FixtureStorage) Find(userId string) (Feed, error) {
+	return fs.current().Find(userId)
+}
+
+func (fs *FixtureStorage) FindPages(userId string, limit PageLimit) (Feed, error) {
+	return fs.current().FindPages(userId, limit)
+}
+
+func (fs *FixtureStorage) Search(query string) (Feed, error) {
+	return fs.current().Search(query)
+}
+
+func (fs *FixtureStorage) FindComments(activityId string) (Feed, error) {
+	return fs.current().FindComments(activityId)
+}
+
+// fixtureStamp sums up the names, sizes and modification times of the JSON
+// files under dir, so that any change to them changes it.
+func fixtureStamp(dir string) (string, error) {
+	var b strings.Builder
+	err := walkJSON(dir, func(p string, info os.FileInfo) error {
+		fmt.Fprintf(&b, "%s %d %d\n", p, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return b.String(), err
+}
+
+func walkJSON(dir string, fn func(string, os.FileInfo) error) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.ToLower(filepath.Ext(p)) != ".json" {
+			return nil
+		}
+		return fn(p, info)
+	})
+}
+
+func loadFixtureIndex(dir string) (*postIndex, error) {
+	idx := newPostIndex("fixtures")
+	err := walkJSON(dir, func(p string, info os.FileInfo) error {
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+			// Not a fixture, like the circles of a Takeout export.
+			return nil
+		}
+		var f fixtureFile
+		err = json.Unmarshal(b, &f)
+		if err != nil {
+			return fmt.Errorf("%s: %s", p, err)
+		}
+		var recordedURL string
+		if f.Kind == "" {
+			if f.Code != http.StatusOK {
+				return nil
+			}
+			recordedURL, b = f.URL, []byte(f.Body)
+			err = json.Unmarshal(b, &f)
+			if err != nil {
+				return fmt.Errorf("%s: %s", p, err)
+			}
+		}
+
+		switch f.Kind {
+		case "plus#person":
+			person := &plus.Person{}
+			err = json.Unmarshal(b, person)
+			if err != nil {
+				return fmt.Errorf("%s: %s", p, err)
+			}
+			// A person recorded by their +Name may be asked for by it.
+			var aliases []string
+			if name := fixtureMatch(fixturePersonR, recordedURL); name != "" && name != person.Id {
+				aliases = append(aliases, name)
+			}
+			idx.addPerson(person, aliases...)
+		case "plus#activityFeed":
+			feed := &plus.ActivityFeed{}
+			err = json.Unmarshal(b, feed)
+			if err != nil {
+				return fmt.Errorf("%s: %s", p, err)
+			}
+			for _, a := range feed.Items {
+				err = addFixtureActivity(idx, a)
+				if err != nil {
+					return fmt.Errorf("%s: %s", p, err)
+				}
+			}
+		case "plus#activity":
+			a := &plus.Activity{}
+			err = json.Unmarshal(b, a)
+			if err != nil {
+				return fmt.Errorf("%s: %s", p, err)
+			}
+			err = addFixtureActivity(idx, a)
+			if err != nil {
+				return fmt.Errorf("%s: %s", p, err)
+			}
+		case "plus#commentFeed":
+			feed := &plus.CommentFeed{}
+			err = json.Unmarshal(b, feed)
+			if err != nil {
+				return fmt.Errorf("%s: %s", p, err)
+			}
+			activityId := fixtureMatch(fixtureCommentsR, recordedURL, feed.Id)
+			if activityId == "" {
+				return fmt.Errorf("%s: can't tell which activity the comments are on", p)
+			}
+			idx.addComments(activityId, feed.Items...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	idx.finish()
+	return idx, nil
+}
+
+// addFixtureActivity adds a to idx, unless it has no object, which every
+// activity from Google+ has and everything that shows it needs.
+func addFixtureActivity(idx *postIndex, a *plus.Activity) error {
+	if a.Object == nil {
+		return fmt.Errorf("activity %q has no object", a.Id)
+	}
+	idx.addActivity(a)
+	return nil
+}
+
+// fixtureMatch is the first thing re captures in any of ss.
+func fixtureMatch(re *regexp.Regexp, ss ...string) string {
+	for _, s := range ss {
+		if m := re.FindStringSubmatch(s); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
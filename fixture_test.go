@@ -0,0 +1,172 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFixturesFromTestdata(t *testing.T) {
+	fs, err := LoadFixtures("./testdata", nullLog())
+	if err != nil {
+		t.Fatalf("LoadFixtures: %s", err)
+	}
+	feed, err := fs.Find("116810148281701144465")
+	if err != nil {
+		t.Fatalf("Find: %s", err)
+	}
+	want, err := fixtureRetriever(t).Find("116810148281701144465")
+	if err != nil {
+		t.Fatalf("fixtureRetriever Find: %s", err)
+	}
+	if feed.Id() != want.Id() || feed.ActorName() != want.ActorName() || len(feed.Items()) != len(want.Items()) {
+		t.Errorf("got feed %q of %q with %d posts, want %q of %q with %d",
+			feed.Id(), feed.ActorName(), len(feed.Items()), want.Id(), want.ActorName(), len(want.Items()))
+	}
+
+	// attachments.json is a hand-written page of activities, without a
+	// person to go with it.
+	feed, err = fs.Find("100000000000000000001")
+	if err != nil {
+		t.Fatalf("Find of a user without a person: %s", err)
+	}
+	if feed.ActorName() == "" || len(feed.Items()) == 0 {
+		t.Errorf("got %d posts of %q", len(feed.Items()), feed.ActorName())
+	}
+
+	cs, err := fs.FindComments(testActivityId)
+	if err != nil {
+		t.Fatalf("FindComments: %s", err)
+	}
+	if len(cs.Items()) != 2 {
+		t.Errorf("want 2 comments, got %d", len(cs.Items()))
+	}
+
+	// The recorded 404s are skipped.
+	if _, err := fs.Find("444"); !isNotFound(err) {
+		t.Errorf("want not found, got %v", err)
+	}
+}
+
+const fixturePerson = `{"kind": "plus#person", "id": "1", "displayName": "Fixture Person", "url": "https://plus.google.com/1"}`
+
+const fixtureFeed = `{
+ "kind": "plus#activityFeed",
+ "id": "tag:google.com,2010:/plus/people/1/activities/public",
+ "items": [
+  {"kind": "plus#activity", "id": "a1", "verb": "post", "published": "2013-01-01T00:00:00.000Z", "updated": "2013-01-01T00:00:00.000Z",
+   "actor": {"id": "1", "displayName": "Fixture Person"}, "object": {"objectType": "note", "content": "First."}}
+ ]
+}`
+
+const fixtureFeed2 = `{
+ "kind": "plus#activityFeed",
+ "items": [
+  {"kind": "plus#activity", "id": "a2", "verb": "post", "published": "2013-02-01T00:00:00.000Z", "updated": "2013-02-01T00:00:00.000Z",
+   "actor": {"id": "1", "displayName": "Fixture Person"}, "object": {"objectType": "note", "content": "Second."}}
+ ]
+}`
+
+func writeFixture(t *testing.T, dir, name, body string, mtime time.Time) {
+	p := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(p, []byte(body), 0644); err != nil {
+		t.Fatalf("WriteFile: %s", err)
+	}
+	if err := os.Chtimes(p, mtime, mtime); err != nil {
+		t.Fatalf("Chtimes: %s", err)
+	}
+}
+
+func TestFixturesReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plus2rss-fixtures")
+	if err != nil {
+		t.Fatalf("TempDir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	mtime := time.Now().Add(-time.Hour)
+	writeFixture(t, dir, "person.json", fixturePerson, mtime)
+	writeFixture(t, dir, "feed.json", fixtureFeed, mtime)
+
+	fs, err := LoadFixtures(dir, nullLog())
+	if err != nil {
+		t.Fatalf("LoadFixtures: %s", err)
+	}
+	assertPosts := func(n int) {
+		feed, err := fs.Find("1")
+		if err != nil {
+			t.Fatalf("Find: %s", err)
+		}
+		if len(feed.Items()) != n {
+			t.Errorf("want %d posts, got %d", n, len(feed.Items()))
+		}
+	}
+	assertPosts(1)
+
+	if reloaded, err := fs.Reload(); reloaded || err != nil {
+		t.Errorf("unchanged dir: want no reload, got %t, %v", reloaded, err)
+	}
+
+	writeFixture(t, dir, "feed2.json", fixtureFeed2, mtime)
+	if reloaded, err := fs.Reload(); !reloaded || err != nil {
+		t.Fatalf("new file: want a reload, got %t, %v", reloaded, err)
+	}
+	assertPosts(2)
+	feed, _ := fs.Find("1")
+	if feed.Items()[0].Id() != "a2" {
+		t.Errorf("newest post: got %q", feed.Items()[0].Id())
+	}
+
+	// A file that's half written fails the reload, and the fixtures already
+	// loaded are kept.
+	writeFixture(t, dir, "feed2.json", fixtureFeed2[:20], mtime.Add(time.Minute))
+	if _, err := fs.Reload(); err == nil {
+		t.Errorf("broken file: want an error")
+	}
+	assertPosts(2)
+
+	os.Remove(filepath.Join(dir, "feed2.json"))
+	if reloaded, err := fs.Reload(); !reloaded || err != nil {
+		t.Fatalf("removed file: want a reload, got %t, %v", reloaded, err)
+	}
+	assertPosts(1)
+}
+
+const fixtureActivity = `{"kind": "plus#activity", "id": "a3", "verb": "post", "published": "2013-03-01T00:00:00.000Z", "updated": "2013-03-01T00:00:00.000Z",
+ "actor": {"id": "2", "displayName": "Single Activity"}, "object": {"objectType": "note", "content": "Third."}}`
+
+const fixtureNoObject = `{"kind": "plus#activity", "id": "a4", "verb": "post", "published": "2013-04-01T00:00:00.000Z",
+ "actor": {"id": "2", "displayName": "Single Activity"}}`
+
+func TestFixtureActivities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plus2rss-fixtures")
+	if err != nil {
+		t.Fatalf("TempDir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	mtime := time.Now().Add(-time.Hour)
+	writeFixture(t, dir, "activity.json", fixtureActivity, mtime)
+
+	fs, err := LoadFixtures(dir, nullLog())
+	if err != nil {
+		t.Fatalf("LoadFixtures: %s", err)
+	}
+	feed, err := fs.Find("2")
+	if err != nil {
+		t.Fatalf("Find: %s", err)
+	}
+	if len(feed.Items()) != 1 || feed.Items()[0].Id() != "a3" {
+		t.Errorf("want the single activity, got %d posts", len(feed.Items()))
+	}
+	if _, err := fs.Search("third"); err != nil {
+		t.Errorf("Search: %s", err)
+	}
+
+	writeFixture(t, dir, "no_object.json", fixtureNoObject, mtime)
+	_, err = fs.Reload()
+	if err == nil || !strings.Contains(err.Error(), "no_object.json") {
+		t.Errorf("want an error naming the file, got %v", err)
+	}
+}
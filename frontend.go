@@ -3,20 +3,30 @@ package main
 import (
 	"bytes"
 	html "html/template"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
 	"regexp"
+	"strconv"
 	"strings"
 	text "text/template"
+	"time"
 
 	"github.com/bmizerany/pat"
-	"google.golang.org/api/googleapi"
 )
 
 var (
+	Body400 = []byte("Bad request.\n")
 	Body404 = []byte("No such feed.\n")
 	Body500 = []byte("Something went wrong. Wait a minute, please.\n")
+	Body501 = []byte("Google+ has no way to get these posts yet.\n")
 	Body503 = []byte("Taking too long.\n")
+	Body429 = []byte("Too many searches. Wait a minute, please.\n")
+
+	noticeUnsupported = "Google+ has no way to get the posts of collections or communities yet."
 
 	userIdPath     = regexp.MustCompile(`/u/(\d+)$`)
 	userIdMetaPath = regexp.MustCompile(`/u_meta/(\d+)$`)
@@ -29,31 +39,91 @@ var (
 type Frontend struct {
 	host              string
 	feedStore         FeedStorage
+	pagedStore        PagedFeedStorage
+	sources           *SourceRegistry
+	searchStore       SearchStorage
+	commentStore      CommentStorage
+	maxPages          int
+	defaults          FeedOptions
+	merger            *Merger
 	askForURLTemplate *html.Template
 	feedMetaTemplate  *html.Template
-	feedTemplate      *text.Template
+	entryTemplate     *html.Template
+	rssTemplate       *text.Template
+}
+
+// feedRenderer writes out a FeedView. *text/template.Template is one.
+type feedRenderer interface {
+	Execute(io.Writer, interface{}) error
 }
 
 //   GET / -> AskForURL (HEAD, too)
 //   GET /u/some_user_id -> UserFeed() (HEAD, too)
+//     ?pages=N&since=2012-09-01 walks back through more than the first page
+//   GET /u/some_user_id.rss -> UserRSSFeed() (HEAD, too)
+//   GET /u/some_user_id.json -> UserJSONFeed() (HEAD, too)
+//     all three also take the query parameters described by feedOptions,
+//     which start from defaults
+//   GET /m/some_list_id -> MergedFeed() (HEAD, too)
+//   GET /m?u=some_user_id,another_user_id -> MergedFeed() (HEAD, too)
+//   GET /collection/some_collection_id -> SourceFeed() (HEAD, too)
+//   GET /community/some_community_id -> SourceFeed() (HEAD, too)
+//     and so on for each other SourceType in sources, at its Prefix
+//   GET /search?q=some+query -> SearchFeed() (HEAD, too)
+//   GET /c/some_activity_id -> CommentsFeed() (HEAD, too)
 //   GET /u_meta/some_user_id -> UserFeedMeta() (HEAD, too)
 //   POST /plus/enqueue -> CheckURLOrUserId
-func NewFrontendMux(fs FeedStorage, host string, templateDir string) http.Handler {
+func NewFrontendMux(fs FeedStorage, pfs PagedFeedStorage, sources *SourceRegistry, search SearchStorage, cs CommentStorage, mg *Merger, maxPages int, defaults FeedOptions, host string, templateDir string) http.Handler {
 	askForURLTemplate := html.Must(html.ParseFiles(templateDir + "/ask_for_url.template.html"))
 	feedMetaTemplate := html.Must(html.ParseFiles(templateDir + "/feed_meta.template.html"))
-	feedTemplate := text.Must(text.ParseFiles(templateDir + "/feed.template.xml"))
+	entryTemplate := html.Must(html.ParseFiles(templateDir + "/entry.template.html"))
+	rssTemplate := text.Must(text.ParseFiles(templateDir + "/rss.template.xml"))
 	host = strings.TrimRight(host, "/")
-	f := &Frontend{host, fs, askForURLTemplate, feedMetaTemplate, feedTemplate}
+	f := &Frontend{host, fs, pfs, sources, search, cs, maxPages, defaults, mg, askForURLTemplate, feedMetaTemplate, entryTemplate, rssTemplate}
 	m := pat.New()
 
 	askForURL := http.HandlerFunc(f.AskForURL)
 	m.Get("/", askForURL)
 	m.Head("/", askForURL)
 
+	// pat would match /u/:user_id to the .rss and .json paths, so they
+	// must be added first.
+	userRSSFeed := http.HandlerFunc(f.UserRSSFeed)
+	m.Get("/u/:user_id.rss", userRSSFeed)
+	m.Head("/u/:user_id.rss", userRSSFeed)
+
+	userJSONFeed := http.HandlerFunc(f.UserJSONFeed)
+	m.Get("/u/:user_id.json", userJSONFeed)
+	m.Head("/u/:user_id.json", userJSONFeed)
+
 	userFeed := http.HandlerFunc(f.UserFeed)
 	m.Get("/u/:user_id", userFeed)
 	m.Head("/u/:user_id", userFeed)
 
+	mergedFeed := http.HandlerFunc(f.MergedFeed)
+	m.Get("/m/:list_id", mergedFeed)
+	m.Head("/m/:list_id", mergedFeed)
+	m.Get("/m", mergedFeed)
+	m.Head("/m", mergedFeed)
+
+	// Users have the routes above instead.
+	for _, st := range sources.Types() {
+		if st.Kind == SourceUser {
+			continue
+		}
+		sourceFeed := f.SourceFeed(st)
+		m.Get(st.Prefix+"/:source_id", sourceFeed)
+		m.Head(st.Prefix+"/:source_id", sourceFeed)
+	}
+
+	searchFeed := http.HandlerFunc(f.SearchFeed)
+	m.Get("/search", searchFeed)
+	m.Head("/search", searchFeed)
+
+	commentsFeed := http.HandlerFunc(f.CommentsFeed)
+	m.Get("/c/:activity_id", commentsFeed)
+	m.Head("/c/:activity_id", commentsFeed)
+
 	userFeedMeta := http.HandlerFunc(f.UserFeedMeta)
 	m.Get("/u_meta/:user_id", userFeedMeta)
 	m.Head("/u_meta/:user_id", userFeedMeta)
@@ -78,7 +148,7 @@ func (f *Frontend) UserFeedMeta(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	feedView := &FeedView{feed, f.host}
+	feedView := f.feedView(feed, "", f.defaults)
 	buf := new(bytes.Buffer)
 	err := f.feedMetaTemplate.Execute(buf, feedView)
 	if err != nil {
@@ -92,28 +162,187 @@ func (f *Frontend) UserFeedMeta(w http.ResponseWriter, r *http.Request) {
 }
 
 func (f *Frontend) UserFeed(w http.ResponseWriter, r *http.Request) {
-	feed := f.verifyUserOrErrorResponse(w, r)
+	f.serveFeed(w, r, f.verifyUserOrErrorResponse, "", atomRenderer{}, `application/atom+xml; charset="utf-8"`)
+}
+
+func (f *Frontend) UserRSSFeed(w http.ResponseWriter, r *http.Request) {
+	f.serveFeed(w, r, f.verifyUserOrErrorResponse, "", f.rssTemplate, `application/rss+xml; charset="utf-8"`)
+}
+
+func (f *Frontend) UserJSONFeed(w http.ResponseWriter, r *http.Request) {
+	f.serveFeed(w, r, f.verifyUserOrErrorResponse, "", jsonFeedRenderer{}, `application/feed+json; charset="utf-8"`)
+}
+
+// MergedFeed is the Atom feed of the posts of a list of users, either one
+// from the -listsFile or the comma-separated ones in the u parameter.
+func (f *Frontend) MergedFeed(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if u := r.FormValue("u"); u != "" {
+		path += "?u=" + url.QueryEscape(u)
+	}
+	f.serveFeed(w, r, f.verifyMergedOrErrorResponse, path, atomRenderer{}, `application/atom+xml; charset="utf-8"`)
+}
+
+// SourceFeed serves the Atom feeds of the Sources of type st, like
+// collections and communities.
+func (f *Frontend) SourceFeed(st *SourceType) http.Handler {
+	find := func(w http.ResponseWriter, r *http.Request) Feed {
+		return f.verifySourceOrErrorResponse(w, r, st)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.serveFeed(w, r, find, r.URL.Path, atomRenderer{}, `application/atom+xml; charset="utf-8"`)
+	})
+}
+
+// SearchFeed is the Atom feed of the newest public posts matching q.
+func (f *Frontend) SearchFeed(w http.ResponseWriter, r *http.Request) {
+	path := "/search?q=" + url.QueryEscape(normalizeQuery(r.FormValue("q")))
+	f.serveFeed(w, r, f.verifySearchOrErrorResponse, path, atomRenderer{}, `application/atom+xml; charset="utf-8"`)
+}
+
+// CommentsFeed is the Atom feed of the comments on an activity.
+func (f *Frontend) CommentsFeed(w http.ResponseWriter, r *http.Request) {
+	f.serveFeed(w, r, f.verifyCommentsOrErrorResponse, r.URL.Path, atomRenderer{}, `application/atom+xml; charset="utf-8"`)
+}
+
+// serveFeed renders the feed find finds with fr. path is the feed's own
+// path, if it isn't a user's feed.
+func (f *Frontend) serveFeed(w http.ResponseWriter, r *http.Request, find func(http.ResponseWriter, *http.Request) Feed, path string, fr feedRenderer, contentType string) {
+	opts, err := feedOptions(r, f.defaults)
+	if err != nil {
+		BadRequest(w, r)
+		return
+	}
+	feed := find(w, r)
 	if feed == nil {
 		return
 	}
+	feed = opts.Apply(feed)
+	if notModified(w, r, feed) {
+		return
+	}
 
-	feedView := &FeedView{feed, f.host}
+	feedView := f.feedView(feed, path, opts)
 	buf := new(bytes.Buffer)
 
-	var err error
 	feedExecuteTiming.Time(func() {
-		err = f.feedTemplate.Execute(buf, feedView)
+		err = fr.Execute(buf, feedView)
 	})
 	if err != nil {
-		log.Printf("ERROR UserFeed template execute: %s", err)
+		log.Printf("ERROR %s feed render: %s", r.URL.Path, err)
 		Sigh500(w, r)
 		return
 	}
-	w.Header().Add("Content-Type", `application/atom+xml; charset="utf-8"`)
+	w.Header().Add("Content-Type", contentType)
 	w.WriteHeader(http.StatusOK)
 	w.Write(buf.Bytes())
 }
 
+func (f *Frontend) feedView(feed Feed, path string, opts FeedOptions) *FeedView {
+	return &FeedView{feed, f.host, path, opts, f.entryTemplate}
+}
+
+func (f *Frontend) verifySourceOrErrorResponse(w http.ResponseWriter, r *http.Request, st *SourceType) Feed {
+	id := r.FormValue(":source_id")
+	if !st.ValidId(id) {
+		NoSuchFeed(w, r)
+		return nil
+	}
+
+	src := Source{st.Kind, id}
+	feed, err := f.sources.FindSource(src)
+	switch {
+	case err == ErrUnsupportedSource:
+		Sigh501(w, r)
+		return nil
+	case isNotFound(err):
+		NoSuchFeed(w, r)
+		return nil
+	case err != nil:
+		log.Printf("ERROR Finding the feed for a %s blew up: %#v", src.Kind, err)
+		Sigh500(w, r)
+		return nil
+	}
+	return feed
+}
+
+func (f *Frontend) verifySearchOrErrorResponse(w http.ResponseWriter, r *http.Request) Feed {
+	feed, err := f.searchStore.Search(r.FormValue("q"))
+	switch {
+	case err == ErrBadQuery:
+		BadRequest(w, r)
+		return nil
+	case err == ErrRateLimited:
+		TooManySearches(w, r)
+		return nil
+	case err != nil:
+		log.Printf("ERROR Searching blew up: %#v", err)
+		Sigh500(w, r)
+		return nil
+	}
+	return feed
+}
+
+func (f *Frontend) verifyCommentsOrErrorResponse(w http.ResponseWriter, r *http.Request) Feed {
+	activityId := r.FormValue(":activity_id")
+	if !activityIdR.MatchString(activityId) {
+		NoSuchFeed(w, r)
+		return nil
+	}
+	feed, err := f.commentStore.FindComments(activityId)
+	switch {
+	case isNotFound(err):
+		NoSuchFeed(w, r)
+		return nil
+	case err != nil:
+		log.Printf("ERROR Finding the comments on an activity blew up: %#v", err)
+		Sigh500(w, r)
+		return nil
+	}
+	return feed
+}
+
+func (f *Frontend) verifyMergedOrErrorResponse(w http.ResponseWriter, r *http.Request) Feed {
+	if f.merger == nil {
+		NoSuchFeed(w, r)
+		return nil
+	}
+
+	var feed Feed
+	var err error
+	if listId := r.FormValue(":list_id"); listId != "" {
+		feed, err = f.merger.FindList(listId)
+	} else {
+		var userIds []string
+		for _, u := range strings.Split(r.FormValue("u"), ",") {
+			if strings.TrimSpace(u) == "" {
+				continue
+			}
+			userId := PlausibleUserId(strings.TrimSpace(u))
+			if userId == "" {
+				BadRequest(w, r)
+				return nil
+			}
+			userIds = append(userIds, userId)
+		}
+		feed, err = f.merger.FindUsers(userIds)
+	}
+
+	switch {
+	case err == ErrNoSuchList || isNotFound(err):
+		NoSuchFeed(w, r)
+		return nil
+	case err == ErrTooManyMembers:
+		BadRequest(w, r)
+		return nil
+	case err != nil:
+		log.Printf("ERROR Finding the feeds for a merged feed blew up: %#v", err)
+		Sigh500(w, r)
+		return nil
+	}
+	return feed
+}
+
 func (f *Frontend) verifyUserOrErrorResponse(w http.ResponseWriter, r *http.Request) Feed {
 	userId := PlausibleUserId(r.FormValue(":user_id"))
 	if userId == "" {
@@ -123,9 +352,20 @@ func (f *Frontend) verifyUserOrErrorResponse(w http.ResponseWriter, r *http.Requ
 		return nil
 	}
 
-	feed, err := f.feedStore.Find(userId)
+	limit, paged, err := pageLimit(r, f.maxPages)
+	if err != nil {
+		BadRequest(w, r)
+		return nil
+	}
+
+	var feed Feed
+	if paged {
+		feed, err = f.pagedStore.FindPages(userId, limit)
+	} else {
+		feed, err = f.feedStore.Find(userId)
+	}
 
-	if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == 404 {
+	if isNotFound(err) {
 		NoSuchFeed(w, r)
 		return nil
 	} else if err != nil {
@@ -134,12 +374,21 @@ func (f *Frontend) verifyUserOrErrorResponse(w http.ResponseWriter, r *http.Requ
 		return nil
 	}
 
+	if _, ok := feed.(*StaleFeed); ok {
+		w.Header().Set("Warning", `110 - "Response is Stale"`)
+	}
 	return feed
 }
 
 func (f *Frontend) AskForURL(w http.ResponseWriter, r *http.Request) {
+	f.askForURL(w, "")
+}
+
+// askForURL renders the front page's form, with notice above it if it's
+// set.
+func (f *Frontend) askForURL(w http.ResponseWriter, notice string) {
 	w.WriteHeader(http.StatusOK)
-	err := f.askForURLTemplate.Execute(w, nil)
+	err := f.askForURLTemplate.Execute(w, notice)
 	if err != nil {
 		log.Printf("ERROR AskForUrl template execute: %s", err)
 	}
@@ -152,20 +401,62 @@ func (f *Frontend) CheckURLOrUserId(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId := PlausibleUserId(urlOrUserId)
+	src, ok := f.sources.Parse(urlOrUserId)
 
-	if userId == "" {
+	if !ok {
 		// TODO: flash[:notice] thing
 		// user name seems invalid
 		http.Redirect(w, r, "/", http.StatusFound)
 		return
 	}
 
-	http.Redirect(w, r, "/u_meta/"+userId, http.StatusFound)
+	if !f.sources.Supported(src) {
+		f.askForURL(w, noticeUnsupported)
+		return
+	}
+
+	if src.Kind != SourceUser {
+		// Only users have a feed meta page.
+		http.Redirect(w, r, f.sources.Path(src), http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/u_meta/"+src.Id, http.StatusFound)
 
 	return
 }
 
+// pageLimit parses the pages and since query parameters. If neither is set,
+// paged is false and only the usual first page of activities is wanted.
+// pages is capped at maxPages.
+func pageLimit(r *http.Request, maxPages int) (limit PageLimit, paged bool, err error) {
+	pages, since := r.FormValue("pages"), r.FormValue("since")
+	if pages == "" && since == "" {
+		return limit, false, nil
+	}
+	limit.Pages = maxPages
+	if pages != "" {
+		n, err := strconv.Atoi(pages)
+		if err != nil {
+			return limit, false, err
+		}
+		if n < maxPages {
+			limit.Pages = n
+		}
+	}
+	if since != "" {
+		limit.Since, err = ParseSince(since)
+		if err != nil {
+			return limit, false, err
+		}
+	}
+	return limit, true, nil
+}
+
+func BadRequest(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(Body400)
+}
+
 func NoSuchFeed(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNotFound)
 	w.Write(Body404)
@@ -195,29 +486,258 @@ func Sigh500(w http.ResponseWriter, r *http.Request) {
 	w.Write(Body500)
 }
 
+func TooManySearches(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "60")
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write(Body429)
+}
+
+func Sigh501(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotImplemented)
+	w.Write(Body501)
+}
+
 func Sigh503(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusInternalServerError)
 	w.Write(Body503)
 }
 
-// FeedView is a helper struct for rendering the feed xml.
+// FeedView is a helper struct for rendering the feed xml. Path is where a
+// feed that isn't a single user's is served from.
 type FeedView struct {
 	Feed
-	Host string
+	Host          string
+	Path          string
+	Options       FeedOptions
+	entryTemplate *html.Template
 }
 
 func (fv *FeedView) AtomURL() string {
+	if !fv.IsUserFeed() {
+		return "http://" + fv.Host + fv.Path
+	}
 	return "http://" + fv.Host + "/u/" + fv.ActorId()
 }
 
+// IsUserFeed is whether the feed is of a single user, and so also has an
+// RSS, JSON Feed and HTML version.
+func (fv *FeedView) IsUserFeed() bool {
+	return fv.Path == ""
+}
+
+func (fv *FeedView) RSSURL() string {
+	return fv.AtomURL() + ".rss"
+}
+
+func (fv *FeedView) JSONFeedURL() string {
+	return fv.AtomURL() + ".json"
+}
+
 func (fv *FeedView) MetaURL() string {
 	return "http://" + fv.Host + "/u_meta/" + fv.ActorId()
 }
 
+// networkFeed is a Feed from somewhere other than Google+, which it names.
+// Feeds that wrap other Feeds are networkFeeds, too, and name the network of
+// the Feed they wrap.
+type networkFeed interface {
+	Network() string
+}
+
+// feedNetwork is the network the feed is from, which is Google+ unless the
+// Feed is a networkFeed.
+func feedNetwork(f Feed) string {
+	if nf, ok := f.(networkFeed); ok {
+		return nf.Network()
+	}
+	return "Google+"
+}
+
+// Title is the feed's actor's name and the network they're on.
 func (fv *FeedView) Title() string {
 	name := fv.Feed.ActorName()
 	if name == "" {
 		name = "Unknown User"
 	}
-	return name + " on Google+"
+	return name + " on " + feedNetwork(fv.Feed)
+}
+
+// Updated is when the feed was last updated. A feed with no posts, which
+// has nothing to say when that was, is as of now, since Atom and RSS feeds
+// must say.
+func (fv *FeedView) Updated() string {
+	if u := fv.Feed.Updated(); u != "" {
+		return u
+	}
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+func (fv *FeedView) RSSUpdated() string {
+	return rssTime(fv.Updated())
+}
+
+func (fv *FeedView) ItemViews() []*ItemView {
+	items := fv.Items()
+	ivs := make([]*ItemView, len(items))
+	for i, a := range items {
+		ivs[i] = &ItemView{a, fv}
+	}
+	return ivs
+}
+
+// ItemView is a helper struct for rendering a feed's entries.
+type ItemView struct {
+	Activity
+	fv *FeedView
+}
+
+// RawContent is the activity's sanitized content, which Google+ hands back
+// as HTML.
+func (iv *ItemView) RawContent() html.HTML {
+	return html.HTML(iv.ContentHTML())
+}
+
+// RawAnnotation is the sanitized HTML a resharer added to a reshared post.
+func (iv *ItemView) RawAnnotation() html.HTML {
+	return html.HTML(sanitizeHTML(iv.Annotation()))
+}
+
+// Description is the HTML of the activity's content and attachments.
+func (iv *ItemView) Description() (string, error) {
+	buf := new(bytes.Buffer)
+	err := iv.fv.entryTemplate.Execute(buf, iv)
+	return buf.String(), err
+}
+
+func (iv *ItemView) RSSPublished() string {
+	return rssTime(iv.Published())
+}
+
+// Title is the activity's title, made with the feed's title policy.
+func (iv *ItemView) Title() string {
+	return entryTitle(iv.Activity, iv.fv.Options.TitlePolicy, iv.fv.Options.TitleLength)
+}
+
+// RepliesURL is where the Atom feed of the activity's comments is, or ""
+// if it has none.
+func (iv *ItemView) RepliesURL() string {
+	if iv.CommentsId() == "" {
+		return ""
+	}
+	return "http://" + iv.fv.Host + "/c/" + iv.CommentsId()
+}
+
+func (iv *ItemView) AttachmentViews() []*AttachmentView {
+	as := iv.Attachments()
+	avs := make([]*AttachmentView, len(as))
+	for i, a := range as {
+		avs[i] = &AttachmentView{a}
+	}
+	return avs
+}
+
+// MediaContents are the photos and video files attached to the activity.
+// Videos that only play on their host's pages aren't included.
+func (iv *ItemView) MediaContents() []MediaContent {
+	var mcs []MediaContent
+	for _, a := range iv.Attachments() {
+		switch {
+		case a.IsPhoto():
+			fi := largestImage(a)
+			if fi == nil {
+				continue
+			}
+			mcs = append(mcs, MediaContent{
+				URL:    fi.URL(),
+				Type:   mimeType(fi.URL(), fi.Type(), "image/jpeg"),
+				Medium: "image",
+				Title:  a.DisplayName(),
+				Width:  fi.Width(),
+				Height: fi.Height(),
+			})
+		case a.IsVideo():
+			v := NewVideo(a)
+			if v.FileURL == "" {
+				continue
+			}
+			mcs = append(mcs, MediaContent{
+				URL:    v.FileURL,
+				Type:   v.FileType,
+				Medium: "video",
+				Title:  a.DisplayName(),
+			})
+		}
+	}
+	return mcs
+}
+
+// Enclosure is the first of the activity's MediaContents, or nil if it has
+// none. RSS allows only one per item.
+func (iv *ItemView) Enclosure() *Enclosure {
+	mcs := iv.MediaContents()
+	if len(mcs) == 0 {
+		return nil
+	}
+	return &Enclosure{mcs[0].URL, mcs[0].Type, 0}
+}
+
+// MediaRSS is whether the feed lists attachments as Media RSS media:content
+// instead of enclosures.
+func (iv *ItemView) MediaRSS() bool {
+	return iv.fv.Options.MediaRSS
+}
+
+// AttachmentView is a helper struct for rendering an entry's attachments.
+type AttachmentView struct {
+	Attachment
+}
+
+// Video is the attachment as a Video, or nil if it isn't one.
+func (av *AttachmentView) Video() *Video {
+	if !av.IsVideo() {
+		return nil
+	}
+	return NewVideo(av.Attachment)
+}
+
+// largestImage is the attachment's full size image if Google+ sent one, then
+// its preview image, and nil if it sent neither.
+func largestImage(a Attachment) Image {
+	if fi := a.FullImage(); fi != nil {
+		return fi
+	}
+	return a.Image()
+}
+
+// Enclosure is a media file attached to a feed item. A Length of 0 means
+// the size is unknown.
+type Enclosure struct {
+	URL    string
+	Type   string
+	Length int64
+}
+
+// mimeType returns typ if it's set, then the type implied by the extension
+// of rawurl's path, and falls back to def.
+func mimeType(rawurl, typ, def string) string {
+	if typ != "" {
+		return typ
+	}
+	u, err := url.Parse(rawurl)
+	if err == nil {
+		if t := mime.TypeByExtension(path.Ext(u.Path)); t != "" {
+			return t
+		}
+	}
+	return def
+}
+
+// rssTime converts the RFC 3339 timestamps Google+ uses to the RFC 822 ones
+// RSS uses.
+func rssTime(s string) string {
+	t := parseTime(s)
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC1123Z)
 }
@@ -1,6 +1,51 @@
 package main
 
-import "testing"
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	plus "google.golang.org/api/plus/v1"
+)
+
+const testHost = "example.com"
+
+// fixtureRetriever is a FeedRetriever that only knows about the user, and
+// the comments on their newest post, in testdata.
+func fixtureRetriever(t *testing.T) *FeedRetriever {
+	tr := &FakeClientTransport{}
+	tr.Add(personResp.URL, "GET", personResp.Response)
+	tr.Add(feedResp.URL, "GET", feedResp.Response)
+	for _, resp := range []*ResponseFixture{activityResp, commentsResp, activity404Resp, comments404Resp} {
+		tr.Add(resp.URL, "GET", resp.Response)
+	}
+	srv, err := plus.New(&http.Client{Transport: tr})
+	if err != nil {
+		t.Fatalf("unable to make Google+ client: %s", err)
+	}
+	return &FeedRetriever{srv, nullLog()}
+}
+
+func testFrontend(t *testing.T) http.Handler {
+	fr := fixtureRetriever(t)
+	return NewFrontendMux(fr, fr, NewPlusSources(fr), NewCachingSearchStorage(fr, time.Minute, time.Minute, 10, 10), fr, nil, 10, FeedOptions{TitlePolicy: TitleDerived, TitleLength: 80}, testHost, "./templates")
+}
+
+func get(h http.Handler, path string, header http.Header) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", path, nil)
+	r.Host = testHost
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
 
 func TestPlausibleUserId(t *testing.T) {
 	type plausibleTest struct {
@@ -41,3 +86,371 @@ func TestPlausibleUserId(t *testing.T) {
 		}
 	}
 }
+
+func TestPageLimit(t *testing.T) {
+	since := time.Date(2012, 9, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		query string
+		limit PageLimit
+		paged bool
+		err   bool
+	}{
+		{"", PageLimit{}, false, false},
+		{"pages=3", PageLimit{Pages: 3}, true, false},
+		{"pages=300", PageLimit{Pages: 10}, true, false},
+		{"since=2012-09-01", PageLimit{Pages: 10, Since: since}, true, false},
+		{"pages=2&since=2012-09-01T00:00:00Z", PageLimit{Pages: 2, Since: since}, true, false},
+		{"pages=lots", PageLimit{}, false, true},
+		{"since=yesterday", PageLimit{}, false, true},
+	}
+	for i, tc := range tests {
+		r, _ := http.NewRequest("GET", "/u/1111?"+tc.query, nil)
+		limit, paged, err := pageLimit(r, 10)
+		if (err != nil) != tc.err {
+			t.Errorf("%d, %q: want error %v, got %v", i, tc.query, tc.err, err)
+			continue
+		}
+		if tc.err {
+			continue
+		}
+		if paged != tc.paged || limit.Pages != tc.limit.Pages || !limit.Since.Equal(tc.limit.Since) {
+			t.Errorf("%d, %q: want %v %v, got %v %v", i, tc.query, tc.limit, tc.paged, limit, paged)
+		}
+	}
+}
+
+func TestUserFeedConditionalGet(t *testing.T) {
+	h := testFrontend(t)
+	path := "/u/116810148281701144465"
+	w := get(h, path, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("want status 200, got %d", w.Code)
+	}
+	etag := w.Header().Get("ETag")
+	lastMod := w.Header().Get("Last-Modified")
+	if etag == "" || lastMod != "Wed, 26 Sep 2012 22:12:46 GMT" {
+		t.Fatalf("missing validators: ETag %q, Last-Modified %q", etag, lastMod)
+	}
+
+	tests := []struct {
+		header http.Header
+		code   int
+	}{
+		{http.Header{"If-None-Match": {etag}}, http.StatusNotModified},
+		{http.Header{"If-None-Match": {`"other", ` + etag}}, http.StatusNotModified},
+		{http.Header{"If-None-Match": {`"other"`}}, http.StatusOK},
+		{http.Header{"If-Modified-Since": {lastMod}}, http.StatusNotModified},
+		{http.Header{"If-Modified-Since": {"Wed, 26 Sep 2012 22:00:00 GMT"}}, http.StatusOK},
+		{http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {lastMod}}, http.StatusOK},
+	}
+	for i, tc := range tests {
+		w := get(h, path, tc.header)
+		if w.Code != tc.code {
+			t.Errorf("%d, %v: want status %d, got %d", i, tc.header, tc.code, w.Code)
+		}
+		if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("%d: 304 had a body", i)
+		}
+	}
+
+	w = get(h, path+"?pages=1", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusOK {
+		t.Errorf("different rendering matched the ETag: got status %d", w.Code)
+	}
+}
+
+type rssDoc struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			Title       string `xml:"title"`
+			GUID        string `xml:"guid"`
+			Description string `xml:"description"`
+			Enclosure   *struct {
+				URL  string `xml:"url,attr"`
+				Type string `xml:"type,attr"`
+			} `xml:"enclosure"`
+			Contents []struct {
+				URL    string `xml:"url,attr"`
+				Medium string `xml:"medium,attr"`
+			} `xml:"http://search.yahoo.com/mrss/ content"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+func TestUserRSSFeed(t *testing.T) {
+	w := get(testFrontend(t), "/u/116810148281701144465.rss", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("want status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != `application/rss+xml; charset="utf-8"` {
+		t.Errorf("Content-Type: got %q", ct)
+	}
+	doc := &rssDoc{}
+	if err := xml.Unmarshal(w.Body.Bytes(), doc); err != nil {
+		t.Fatalf("RSS didn't parse: %s", err)
+	}
+	if doc.Channel.Title != "Russ Cox on Google+" {
+		t.Errorf("title: got %q", doc.Channel.Title)
+	}
+	items := doc.Channel.Items
+	if len(items) != 20 {
+		t.Fatalf("want 20 items, got %d", len(items))
+	}
+	if items[0].GUID != "https://plus.google.com/116810148281701144465/posts/GKfM9zWMXYp" {
+		t.Errorf("guid: got %q", items[0].GUID)
+	}
+	if items[0].Enclosure != nil {
+		t.Errorf("article got an enclosure")
+	}
+	enc := items[7].Enclosure
+	if enc == nil || enc.Type != "image/jpeg" {
+		t.Errorf("photo enclosure: got %+v", enc)
+	}
+	if items[6].Enclosure != nil {
+		t.Errorf("YouTube video got an enclosure: %+v", items[6].Enclosure)
+	}
+	desc := items[6].Description
+	if !strings.Contains(desc, "https://www.youtube.com/watch?v=wrxdWkjmhKg") || strings.Contains(desc, "shockwave") {
+		t.Errorf("YouTube video description: %s", desc)
+	}
+
+	w = get(testFrontend(t), "/u/116810148281701144465.rss?media=mrss", nil)
+	doc = &rssDoc{}
+	if err := xml.Unmarshal(w.Body.Bytes(), doc); err != nil {
+		t.Fatalf("RSS didn't parse: %s", err)
+	}
+	item := doc.Channel.Items[7]
+	if item.Enclosure != nil || len(item.Contents) != 1 || item.Contents[0].Medium != "image" {
+		t.Errorf("media=mrss: enclosure %+v, media:content %+v", item.Enclosure, item.Contents)
+	}
+}
+
+func TestUserJSONFeed(t *testing.T) {
+	h := testFrontend(t)
+	path := "/u/116810148281701144465.json"
+	w := get(h, path, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("want status 200, got %d", w.Code)
+	}
+	jf := &JSONFeed{}
+	if err := json.Unmarshal(w.Body.Bytes(), jf); err != nil {
+		t.Fatalf("JSON Feed didn't parse: %s", err)
+	}
+	if jf.Version != "https://jsonfeed.org/version/1.1" || jf.FeedURL != "http://example.com"+path {
+		t.Errorf("version %q, feed_url %q", jf.Version, jf.FeedURL)
+	}
+	if len(jf.Items) != 20 {
+		t.Fatalf("want 20 items, got %d", len(jf.Items))
+	}
+	item := jf.Items[7]
+	if item.DatePublished != "2012-07-14T22:32:31.000Z" || item.ExternalURL != "http://en.wikipedia.org/wiki/AT%26T" {
+		t.Errorf("date_published %q, external_url %q", item.DatePublished, item.ExternalURL)
+	}
+	if len(item.Attachments) != 1 || item.Attachments[0].MIMEType != "image/jpeg" || item.Attachments[0].Plus2RSS == nil {
+		t.Errorf("photo attachment: got %+v", item.Attachments)
+	}
+
+	w = get(h, path, http.Header{"If-None-Match": {w.Header().Get("ETag")}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("conditional GET: want 304, got %d", w.Code)
+	}
+}
+
+func TestReshares(t *testing.T) {
+	h := testFrontend(t)
+	path := "/u/116810148281701144465"
+	w := get(h, path, nil)
+	doc := &atomDoc{}
+	if err := xml.Unmarshal(w.Body.Bytes(), doc); err != nil {
+		t.Fatalf("Atom didn't parse: %s", err)
+	}
+	if len(doc.Entries) != 20 {
+		t.Fatalf("want 20 entries, got %d", len(doc.Entries))
+	}
+	e := doc.Entries[3]
+	if len(e.Contributors) != 1 || e.Contributors[0].Name != "Rob Pike" {
+		t.Errorf("reshare contributors: got %+v", e.Contributors)
+	}
+	if !strings.Contains(e.Content.Body, "https://plus.google.com/101960720994009339267/posts/Rz1udTvtiMg") {
+		t.Errorf("reshare content doesn't link the original post: %s", e.Content.Body)
+	}
+	if !strings.Contains(doc.Entries[10].Content.Body, "Be sure to stop by the Google home page today.") {
+		t.Errorf("reshare content is missing the annotation: %s", doc.Entries[10].Content.Body)
+	}
+	if len(doc.Entries[0].Contributors) != 0 {
+		t.Errorf("non-reshare has contributors: %+v", doc.Entries[0].Contributors)
+	}
+
+	w = get(h, path+"?reshares=false", nil)
+	doc = &atomDoc{}
+	if err := xml.Unmarshal(w.Body.Bytes(), doc); err != nil {
+		t.Fatalf("Atom didn't parse: %s", err)
+	}
+	if len(doc.Entries) != 18 {
+		t.Errorf("reshares=false: want 18 entries, got %d", len(doc.Entries))
+	}
+
+	w = get(h, path+"?reshares=maybe", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("reshares=maybe: want 400, got %d", w.Code)
+	}
+}
+
+func TestTitleOption(t *testing.T) {
+	h := testFrontend(t)
+	path := "/u/116810148281701144465.json"
+	titles := map[string]string{
+		"":               "This is fascinating.",
+		"?title=api":     "This is fascinating. The wikipedia article for AT&T gives the company history as starting in 1984...",
+		"?title=derived": "This is fascinating.",
+	}
+	for query, title := range titles {
+		jf := &JSONFeed{}
+		if err := json.Unmarshal(get(h, path+query, nil).Body.Bytes(), jf); err != nil {
+			t.Fatalf("%s: JSON Feed didn't parse: %s", query, err)
+		}
+		if jf.Items[7].Title != title {
+			t.Errorf("%s: want title %q, got %q", query, title, jf.Items[7].Title)
+		}
+	}
+	if w := get(h, path+"?title=clickbait", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("title=clickbait: want 400, got %d", w.Code)
+	}
+}
+
+func TestMergedFeed(t *testing.T) {
+	fr := fixtureRetriever(t)
+	lists := map[string]*MergedList{
+		"team": {Title: "The team", Users: []string{"116810148281701144465"}},
+	}
+	mg := NewMerger(fr, lists, 2, 10, 5, nullLog())
+	h := NewFrontendMux(fr, fr, NewPlusSources(fr), fr, fr, mg, 10, FeedOptions{}, testHost, "./templates")
+
+	for _, path := range []string{"/m/team", "/m?u=116810148281701144465,https://plus.google.com/116810148281701144465"} {
+		w := get(h, path, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: want status 200, got %d", path, w.Code)
+		}
+		doc := &atomDoc{}
+		if err := xml.Unmarshal(w.Body.Bytes(), doc); err != nil {
+			t.Fatalf("%s: Atom didn't parse: %s", path, err)
+		}
+		if len(doc.Entries) != 5 {
+			t.Errorf("%s: want 5 entries, got %d", path, len(doc.Entries))
+		}
+		if len(doc.Entries) > 0 && doc.Entries[0].Author.Name != "Russ Cox" {
+			t.Errorf("%s: entry author: got %q", path, doc.Entries[0].Author.Name)
+		}
+	}
+
+	codes := map[string]int{
+		"/m/nope":         http.StatusNotFound,
+		"/m":              http.StatusNotFound,
+		"/m?u=not+a+user": http.StatusBadRequest,
+	}
+	for path, code := range codes {
+		if w := get(h, path, nil); w.Code != code {
+			t.Errorf("%s: want %d, got %d", path, code, w.Code)
+		}
+	}
+}
+
+func TestSourceRoutes(t *testing.T) {
+	h := testFrontend(t)
+	codes := map[string]int{
+		"/collection/QaXrV":                http.StatusNotImplemented,
+		"/community/101996519396366113329": http.StatusNotImplemented,
+		"/community/golang":                http.StatusNotFound,
+	}
+	for path, code := range codes {
+		if w := get(h, path, nil); w.Code != code {
+			t.Errorf("%s: want %d, got %d", path, code, w.Code)
+		}
+	}
+
+	redirects := map[string]string{
+		"https://plus.google.com/116810148281701144465": "/u_meta/116810148281701144465",
+		"https://example.com/":                          "/",
+	}
+	enqueue := func(in string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("POST", "/plus/enqueue", strings.NewReader(url.Values{"url_or_user_id": {in}}.Encode()))
+		r.Host = testHost
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	for in, loc := range redirects {
+		w := enqueue(in)
+		if w.Code != http.StatusFound || w.Header().Get("Location") != loc {
+			t.Errorf("%s: want a redirect to %s, got %d %s", in, loc, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	// Collections and communities can't be served yet, so the form says so
+	// instead of sending readers to a feed that always fails.
+	for _, in := range []string{"https://plus.google.com/collection/QaXrV", "https://plus.google.com/communities/101996519396366113329"} {
+		w := enqueue(in)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "collections or communities") {
+			t.Errorf("%s: want the form with a notice, got %d %q", in, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestSearchFeed(t *testing.T) {
+	h := NewFrontendMux(nil, nil, NewPlusSources(&countingStorage{}), &searchCounter{}, nil, nil, 10, FeedOptions{}, testHost, "./templates")
+	w := get(h, "/search?q=Go+Lang", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("want status 200, got %d", w.Code)
+	}
+	doc := &atomDoc{}
+	if err := xml.Unmarshal(w.Body.Bytes(), doc); err != nil {
+		t.Fatalf("Atom didn't parse: %s", err)
+	}
+	if doc.Title != `Search for "Go Lang" on Google+` {
+		t.Errorf("title: got %q", doc.Title)
+	}
+
+	h = testFrontend(t)
+	if w := get(h, "/search?q=++", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("empty query: want 400, got %d", w.Code)
+	}
+}
+
+func TestCommentsFeed(t *testing.T) {
+	h := testFrontend(t)
+	w := get(h, "/c/"+testActivityId, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("want status 200, got %d: %s", w.Code, w.Body)
+	}
+	doc := &atomDoc{}
+	if err := xml.Unmarshal(w.Body.Bytes(), doc); err != nil {
+		t.Fatalf("Atom didn't parse: %s", err)
+	}
+	if doc.Title != "Comments on a post by Russ Cox on Google+" {
+		t.Errorf("title: got %q", doc.Title)
+	}
+	if len(doc.Entries) != 2 {
+		t.Fatalf("want 2 entries, got %d", len(doc.Entries))
+	}
+	e := doc.Entries[0]
+	postURL := "https://plus.google.com/116810148281701144465/posts/GKfM9zWMXYp"
+	if e.InReplyTo == nil || e.InReplyTo.Ref != postURL {
+		t.Errorf("thr:in-reply-to: want ref %q, got %+v", postURL, e.InReplyTo)
+	}
+	if e.Title != "memmove is the one you want." {
+		t.Errorf("entry title: got %q", e.Title)
+	}
+	for _, l := range e.Links {
+		if l.Rel == "replies" {
+			t.Errorf("comments shouldn't have a replies link")
+		}
+	}
+
+	for _, path := range []string{"/c/nosuchactivity", "/c/no%20such"} {
+		if w := get(h, path, nil); w.Code != http.StatusNotFound {
+			t.Errorf("%s: want 404, got %d", path, w.Code)
+		}
+	}
+}
@@ -9,7 +9,59 @@ var (
 	findSuccesses     = metrics.NewCounter()
 	findFailures      = metrics.NewCounter()
 	findTimer         = metrics.NewTimer()
+	pagesFetched      = metrics.NewCounter()
 	feedExecuteTiming = metrics.NewTimer()
+	cacheHits         = metrics.NewCounter()
+	cacheMisses       = metrics.NewCounter()
+	cacheEvictions    = metrics.NewCounter()
+
+	notModifiedResponses = metrics.NewCounter()
+
+	notFoundCacheHits      = metrics.NewCounter()
+	notFoundCacheMisses    = metrics.NewCounter()
+	notFoundCacheStores    = metrics.NewCounter()
+	notFoundCacheEvictions = metrics.NewCounter()
+	notFoundCacheClears    = metrics.NewCounter()
+
+	coalescedFinds         = metrics.NewCounter()
+	coalescedUpstreamFinds = metrics.NewCounter()
+
+	staleServed          = metrics.NewCounter()
+	staleRefreshFailures = metrics.NewCounter()
+	staleEvictions       = metrics.NewCounter()
+
+	archiveMerges      = metrics.NewCounter()
+	archiveFailures    = metrics.NewCounter()
+	archivedActivities = metrics.NewCounter()
+
+	scheduledRefreshes       = metrics.NewCounter()
+	scheduledRefreshFailures = metrics.NewCounter()
+
+	mergedFinds       = metrics.NewCounter()
+	mergeMemberErrors = metrics.NewCounter()
+
+	unsupportedSourceFinds = metrics.NewCounter()
+
+	searchAttempts        = metrics.NewCounter()
+	searchFailures        = metrics.NewCounter()
+	searchCacheHits       = metrics.NewCounter()
+	searchCacheMisses     = metrics.NewCounter()
+	searchCacheEvictions  = metrics.NewCounter()
+	searchRecentEvictions = metrics.NewCounter()
+	searchRecentHits      = metrics.NewCounter()
+	searchRateLimited     = metrics.NewCounter()
+
+	commentFinds          = metrics.NewCounter()
+	commentFindFailures   = metrics.NewCounter()
+	commentCacheHits      = metrics.NewCounter()
+	commentCacheMisses    = metrics.NewCounter()
+	commentCacheEvictions = metrics.NewCounter()
+
+	fixtureReloads        = metrics.NewCounter()
+	fixtureReloadFailures = metrics.NewCounter()
+
+	mastodonFinds        = metrics.NewCounter()
+	mastodonFindFailures = metrics.NewCounter()
 )
 
 func init() {
@@ -17,5 +69,67 @@ func init() {
 	registry.Register("feed_retriever_find_successes", findSuccesses)
 	registry.Register("feed_retriever_find_failures", findFailures)
 	registry.Register("feed_retriever_find_timing", findTimer)
+	registry.Register("feed_retriever_pages_fetched", pagesFetched)
 	registry.Register("frontend_user_feed_execute_timing", feedExecuteTiming)
+	registry.Register("frontend_not_modified_responses", notModifiedResponses)
+	registry.Register("feed_cache_hits", cacheHits)
+	registry.Register("feed_cache_misses", cacheMisses)
+	registry.Register("feed_cache_evictions", cacheEvictions)
+	registry.Register("feed_not_found_cache_hits", notFoundCacheHits)
+	registry.Register("feed_not_found_cache_misses", notFoundCacheMisses)
+	registry.Register("feed_not_found_cache_stores", notFoundCacheStores)
+	registry.Register("feed_not_found_cache_evictions", notFoundCacheEvictions)
+	registry.Register("feed_not_found_cache_clears", notFoundCacheClears)
+	registry.Register("feed_coalesce_coalesced_finds", coalescedFinds)
+	registry.Register("feed_coalesce_upstream_finds", coalescedUpstreamFinds)
+	registry.Register("feed_stale_served", staleServed)
+	registry.Register("feed_stale_refresh_failures", staleRefreshFailures)
+	registry.Register("feed_stale_evictions", staleEvictions)
+	registry.Register("feed_archive_merges", archiveMerges)
+	registry.Register("feed_archive_failures", archiveFailures)
+	registry.Register("feed_archive_new_activities", archivedActivities)
+	registry.Register("refresh_scheduler_refreshes", scheduledRefreshes)
+	registry.Register("refresh_scheduler_refresh_failures", scheduledRefreshFailures)
+	registry.Register("feed_merge_finds", mergedFinds)
+	registry.Register("feed_merge_member_errors", mergeMemberErrors)
+	registry.Register("feed_source_unsupported_finds", unsupportedSourceFinds)
+	registry.Register("search_attempts", searchAttempts)
+	registry.Register("search_failures", searchFailures)
+	registry.Register("search_cache_hits", searchCacheHits)
+	registry.Register("search_cache_misses", searchCacheMisses)
+	registry.Register("search_cache_evictions", searchCacheEvictions)
+	registry.Register("search_recent_evictions", searchRecentEvictions)
+	registry.Register("search_recent_hits", searchRecentHits)
+	registry.Register("search_rate_limited", searchRateLimited)
+	registry.Register("comments_find_attempts", commentFinds)
+	registry.Register("comments_find_failures", commentFindFailures)
+	registry.Register("comments_cache_hits", commentCacheHits)
+	registry.Register("comments_cache_misses", commentCacheMisses)
+	registry.Register("comments_cache_evictions", commentCacheEvictions)
+	registry.Register("fixture_reloads", fixtureReloads)
+	registry.Register("fixture_reload_failures", fixtureReloadFailures)
+	registry.Register("mastodon_find_attempts", mastodonFinds)
+	registry.Register("mastodon_find_failures", mastodonFindFailures)
+}
+
+func registerStaleGauges(ss *StaleFeedStorage) {
+	registry.Register("feed_stale_users", metrics.NewFunctionalGauge(func() int64 {
+		return int64(len(ss.StaleAges()))
+	}))
+	registry.Register("feed_stale_max_age_seconds", metrics.NewFunctionalGauge(func() int64 {
+		ages := ss.StaleAges()
+		if len(ages) == 0 {
+			return 0
+		}
+		return int64(ages[0].Age.Seconds())
+	}))
+}
+
+func registerSchedulerGauges(rs *RefreshScheduler) {
+	registry.Register("refresh_scheduler_queue_depth", metrics.NewFunctionalGauge(func() int64 {
+		return int64(rs.QueueDepth())
+	}))
+	registry.Register("refresh_scheduler_subscriptions", metrics.NewFunctionalGauge(func() int64 {
+		return int64(rs.Subscriptions())
+	}))
 }
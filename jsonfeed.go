@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const jsonFeedVersion = "https://jsonfeed.org/version/1.1"
+
+// jsonFeedRenderer is the feedRenderer for JSON Feed 1.1.
+type jsonFeedRenderer struct{}
+
+func (jsonFeedRenderer) Execute(w io.Writer, data interface{}) error {
+	jf, err := NewJSONFeed(data.(*FeedView))
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(jf)
+}
+
+// JSONFeed is a JSON Feed 1.1 document. See https://jsonfeed.org/version/1.1
+type JSONFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url,omitempty"`
+	FeedURL     string           `json:"feed_url"`
+	Authors     []JSONFeedAuthor `json:"authors,omitempty"`
+	Items       []JSONFeedItem   `json:"items"`
+}
+
+type JSONFeedAuthor struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+type JSONFeedItem struct {
+	Id            string               `json:"id"`
+	URL           string               `json:"url,omitempty"`
+	ExternalURL   string               `json:"external_url,omitempty"`
+	Title         string               `json:"title,omitempty"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text,omitempty"`
+	DatePublished string               `json:"date_published,omitempty"`
+	DateModified  string               `json:"date_modified,omitempty"`
+	Authors       []JSONFeedAuthor     `json:"authors,omitempty"`
+	Attachments   []JSONFeedAttachment `json:"attachments,omitempty"`
+}
+
+// JSONFeedAttachment is a photo or video file attached to an item. JSON Feed has
+// no place for an image's dimensions, so they go in the _plus2rss
+// extension.
+type JSONFeedAttachment struct {
+	URL      string              `json:"url"`
+	MIMEType string              `json:"mime_type"`
+	Title    string              `json:"title,omitempty"`
+	Plus2RSS *JSONFeedAttachSize `json:"_plus2rss,omitempty"`
+}
+
+type JSONFeedAttachSize struct {
+	Width  int64 `json:"width,omitempty"`
+	Height int64 `json:"height,omitempty"`
+}
+
+func NewJSONFeed(fv *FeedView) (*JSONFeed, error) {
+	home := "https://plus.google.com/" + fv.ActorId()
+	jf := &JSONFeed{
+		Version:     jsonFeedVersion,
+		Title:       fv.Title(),
+		HomePageURL: home,
+		FeedURL:     fv.JSONFeedURL(),
+		Authors:     []JSONFeedAuthor{{fv.ActorName(), home}},
+		Items:       []JSONFeedItem{},
+	}
+	for _, iv := range fv.ItemViews() {
+		content, err := iv.Description()
+		if err != nil {
+			return nil, err
+		}
+		item := JSONFeedItem{
+			Id:            iv.URL(),
+			URL:           iv.URL(),
+			Title:         iv.Title(),
+			ContentHTML:   content,
+			ContentText:   iv.ContentText(),
+			DatePublished: iv.Published(),
+			DateModified:  iv.Updated(),
+			Authors:       []JSONFeedAuthor{{Name: iv.ActorName()}},
+		}
+		for _, a := range iv.Attachments() {
+			switch {
+			case a.IsArticle():
+				if item.ExternalURL == "" {
+					item.ExternalURL = a.URL()
+				}
+			case a.IsVideo():
+				v := NewVideo(a)
+				if v.FileURL == "" && item.ExternalURL == "" {
+					item.ExternalURL = v.WatchURL
+				}
+			}
+		}
+		for _, mc := range iv.MediaContents() {
+			ja := JSONFeedAttachment{URL: mc.URL, MIMEType: mc.Type, Title: mc.Title}
+			if mc.Width != 0 || mc.Height != 0 {
+				ja.Plus2RSS = &JSONFeedAttachSize{mc.Width, mc.Height}
+			}
+			item.Attachments = append(item.Attachments, ja)
+		}
+		jf.Items = append(jf.Items, item)
+	}
+	return jf, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"google.golang.org/api/googleapi"
+)
+
+// ErrNoKeys is returned for a key file without any keys in it.
+var ErrNoKeys = errors.New("no Google simple keys in the key file")
+
+// KeyPoolTransport adds a Google simple key to each request, taking turns
+// with each of its keys. A key that gets a 403 saying its quota is used up
+// is left out of the turns until cooldown has passed. If every key is
+// cooling down, the one that will be done soonest is used anyway, so that
+// requests fail with Google's error instead of one of ours.
+//
+// Each key's requests, errors and cooldowns are counted in /vars as
+// api_key_N_requests and so on, where N is the key's place in the pool, so
+// that the keys themselves aren't shown.
+type KeyPoolTransport struct {
+	Transport http.RoundTripper
+
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu   sync.Mutex
+	keys []*poolKey
+	next int
+}
+
+type poolKey struct {
+	key       string
+	coolUntil time.Time
+
+	requests  metrics.Counter
+	errors    metrics.Counter
+	cooldowns metrics.Counter
+}
+
+func NewKeyPoolTransport(keys []string, cooldown time.Duration, rt http.RoundTripper, reg metrics.Registry) *KeyPoolTransport {
+	t := &KeyPoolTransport{Transport: rt, cooldown: cooldown, now: time.Now}
+	for i, k := range keys {
+		pk := &poolKey{
+			key:       k,
+			requests:  metrics.NewCounter(),
+			errors:    metrics.NewCounter(),
+			cooldowns: metrics.NewCounter(),
+		}
+		reg.Register(fmt.Sprintf("api_key_%d_requests", i), pk.requests)
+		reg.Register(fmt.Sprintf("api_key_%d_errors", i), pk.errors)
+		reg.Register(fmt.Sprintf("api_key_%d_cooldowns", i), pk.cooldowns)
+		t.keys = append(t.keys, pk)
+	}
+	return t
+}
+
+// ReadKeys reads the keys in path, one to a line. Blank lines and lines
+// starting with # are skipped.
+func ReadKeys(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var keys []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	return keys, nil
+}
+
+func (t *KeyPoolTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	pk := t.pick()
+	pk.requests.Inc(1)
+
+	// RoundTrippers mustn't change the requests they're given.
+	r2 := new(http.Request)
+	*r2 = *r
+	u := *r.URL
+	q := u.Query()
+	q.Set("key", pk.key)
+	u.RawQuery = q.Encode()
+	r2.URL = &u
+
+	res, err := t.Transport.RoundTrip(r2)
+	if err != nil {
+		pk.errors.Inc(1)
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		pk.errors.Inc(1)
+	}
+	if res.StatusCode == http.StatusForbidden {
+		quotaUsed, err := quotaExceeded(res)
+		if err != nil {
+			return nil, err
+		}
+		if quotaUsed {
+			t.coolDown(pk)
+		}
+	}
+	return res, nil
+}
+
+// pick is the next key whose turn it is that isn't cooling down, or the
+// one done cooling down soonest if they all are.
+func (t *KeyPoolTransport) pick() *poolKey {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	var soonest *poolKey
+	for i := 0; i < len(t.keys); i++ {
+		pk := t.keys[(t.next+i)%len(t.keys)]
+		if !now.Before(pk.coolUntil) {
+			t.next = (t.next + i + 1) % len(t.keys)
+			return pk
+		}
+		if soonest == nil || pk.coolUntil.Before(soonest.coolUntil) {
+			soonest = pk
+		}
+	}
+	return soonest
+}
+
+func (t *KeyPoolTransport) coolDown(pk *poolKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pk.coolUntil = t.now().Add(t.cooldown)
+	pk.cooldowns.Inc(1)
+}
+
+// quotaExceeded reports whether res is a Google API error saying the key's
+// daily or per-second quota is used up. res's body is left to be read
+// again.
+func quotaExceeded(res *http.Response) (bool, error) {
+	b, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return false, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	copied := *res
+	copied.Body = ioutil.NopCloser(bytes.NewReader(b))
+	gerr, ok := googleapi.CheckResponse(&copied).(*googleapi.Error)
+	if !ok {
+		return false, nil
+	}
+	for _, e := range gerr.Errors {
+		if e.Reason == "dailyLimitExceeded" || e.Reason == "rateLimitExceeded" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
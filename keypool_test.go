@@ -0,0 +1,160 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+const quotaErrorBody = `{"error": {"errors": [{"domain": "usageLimits", "reason": "%s", "message": "Limit Exceeded"}], "code": 403, "message": "Limit Exceeded"}}`
+
+// keyRecorder is a RoundTripper that remembers the key each request was
+// made with, and answers with the responses in answers, if any, by key.
+type keyRecorder struct {
+	keys    []string
+	answers map[string]func() *http.Response
+}
+
+func (kr *keyRecorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	key := r.URL.Query().Get("key")
+	kr.keys = append(kr.keys, key)
+	if answer, ok := kr.answers[key]; ok {
+		return answer(), nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+}
+
+func forbidden(reason string) func() *http.Response {
+	return func() *http.Response {
+		body := strings.Replace(quotaErrorBody, "%s", reason, 1)
+		return &http.Response{StatusCode: http.StatusForbidden, Header: make(http.Header), Body: ioutil.NopCloser(strings.NewReader(body))}
+	}
+}
+
+func keyPoolGet(t *testing.T, kp *KeyPoolTransport, n int) {
+	for i := 0; i < n; i++ {
+		r, _ := http.NewRequest("GET", "https://www.googleapis.com/plus/v1/people/1?alt=json", nil)
+		res, err := kp.RoundTrip(r)
+		if err != nil {
+			t.Fatalf("RoundTrip: %s", err)
+		}
+		if _, err := ioutil.ReadAll(res.Body); err != nil {
+			t.Fatalf("body wasn't left readable: %s", err)
+		}
+		if r.URL.Query().Get("key") != "" {
+			t.Fatalf("the request given to RoundTrip was changed: %s", r.URL)
+		}
+	}
+}
+
+func TestKeyPoolRoundRobin(t *testing.T) {
+	kr := &keyRecorder{}
+	kp := NewKeyPoolTransport([]string{"a", "b", "c"}, time.Minute, kr, metrics.NewRegistry())
+	keyPoolGet(t, kp, 5)
+	if want := []string{"a", "b", "c", "a", "b"}; !reflect.DeepEqual(kr.keys, want) {
+		t.Errorf("want keys %v, got %v", want, kr.keys)
+	}
+}
+
+func TestKeyPoolCooldown(t *testing.T) {
+	kr := &keyRecorder{answers: map[string]func() *http.Response{"b": forbidden("dailyLimitExceeded")}}
+	reg := metrics.NewRegistry()
+	kp := NewKeyPoolTransport([]string{"a", "b", "c"}, time.Hour, kr, reg)
+	now := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+	kp.now = func() time.Time { return now }
+
+	keyPoolGet(t, kp, 6)
+	if want := []string{"a", "b", "c", "a", "c", "a"}; !reflect.DeepEqual(kr.keys, want) {
+		t.Errorf("want b rested after its 403, got keys %v", kr.keys)
+	}
+
+	now = now.Add(time.Hour)
+	kr.keys = nil
+	delete(kr.answers, "b")
+	keyPoolGet(t, kp, 3)
+	if want := []string{"b", "c", "a"}; !reflect.DeepEqual(kr.keys, want) {
+		t.Errorf("want b back after its cooldown, got keys %v", kr.keys)
+	}
+
+	counts := map[string]int64{
+		"api_key_0_requests":  4,
+		"api_key_1_requests":  2,
+		"api_key_1_errors":    1,
+		"api_key_1_cooldowns": 1,
+		"api_key_2_requests":  3,
+		"api_key_2_errors":    0,
+	}
+	for name, want := range counts {
+		c, ok := reg.Get(name).(metrics.Counter)
+		if !ok {
+			t.Errorf("%s isn't registered", name)
+			continue
+		}
+		if c.Count() != want {
+			t.Errorf("%s: want %d, got %d", name, want, c.Count())
+		}
+	}
+}
+
+func TestKeyPoolAllCooling(t *testing.T) {
+	kr := &keyRecorder{answers: map[string]func() *http.Response{
+		"a": forbidden("rateLimitExceeded"),
+		"b": forbidden("rateLimitExceeded"),
+	}}
+	kp := NewKeyPoolTransport([]string{"a", "b"}, time.Hour, kr, metrics.NewRegistry())
+	now := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+	kp.now = func() time.Time { return now }
+	keyPoolGet(t, kp, 1)
+	now = now.Add(time.Minute)
+	keyPoolGet(t, kp, 2)
+	if want := []string{"a", "b", "a"}; !reflect.DeepEqual(kr.keys, want) {
+		t.Errorf("want the key done cooling down soonest, got keys %v", kr.keys)
+	}
+}
+
+func TestKeyPoolOtherForbidden(t *testing.T) {
+	kr := &keyRecorder{answers: map[string]func() *http.Response{"a": forbidden("forbidden")}}
+	kp := NewKeyPoolTransport([]string{"a", "b"}, time.Hour, kr, metrics.NewRegistry())
+	keyPoolGet(t, kp, 3)
+	if want := []string{"a", "b", "a"}; !reflect.DeepEqual(kr.keys, want) {
+		t.Errorf("only quota errors should rest a key, got keys %v", kr.keys)
+	}
+}
+
+func TestReadKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plus2rss-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"one":  "onlykey\n",
+		"many": "# staging\nkey1\n\n  key2  \nkey3",
+		"none": "# nothing yet\n\n",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := map[string][]string{
+		"one":  {"onlykey"},
+		"many": {"key1", "key2", "key3"},
+	}
+	for name, want := range tests {
+		keys, err := ReadKeys(filepath.Join(dir, name))
+		if err != nil || !reflect.DeepEqual(keys, want) {
+			t.Errorf("%s: want %v, got %v, %v", name, want, keys, err)
+		}
+	}
+	if _, err := ReadKeys(filepath.Join(dir, "none")); err != ErrNoKeys {
+		t.Errorf("want ErrNoKeys, got %v", err)
+	}
+}
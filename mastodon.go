@@ -0,0 +1,446 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+)
+
+// SourceMastodon is the Kind of the SourceType of Mastodon accounts.
+const SourceMastodon = "mastodon"
+
+var (
+	mastodonUrlR    = regexp.MustCompile(`^https?://([A-Za-z0-9.-]+)/@([A-Za-z0-9_]+)/?(?:$|\d+$)`)
+	mastodonHandleR = regexp.MustCompile(`^@?([A-Za-z0-9_]+)@([A-Za-z0-9.-]+)$`)
+)
+
+// MastodonStorage finds the public posts of Mastodon accounts with the
+// Mastodon client API, which other servers, like Pleroma, also speak.
+// Accounts are identified as USER@INSTANCE, and only those on the
+// instances it's made with can be found, so that the server can't be used
+// to fetch from anywhere else. Errors from the API are *googleapi.Errors,
+// so that isNotFound works on them.
+type MastodonStorage struct {
+	client    *http.Client
+	instances map[string]bool
+	entries   int
+	lg        *log.Logger
+}
+
+func NewMastodonStorage(client *http.Client, instances []string, entries int, lg *log.Logger) *MastodonStorage {
+	m := &MastodonStorage{client, make(map[string]bool), entries, lg}
+	for _, inst := range instances {
+		if inst = strings.ToLower(strings.TrimSpace(inst)); inst != "" {
+			m.instances[inst] = true
+		}
+	}
+	return m
+}
+
+// SourceType registers Mastodon accounts, with their feeds found in fs,
+// which is usually m wrapped in a cache.
+func (m *MastodonStorage) SourceType(fs FeedStorage) *SourceType {
+	return &SourceType{
+		Kind:    SourceMastodon,
+		Prefix:  "/mastodon",
+		Parse:   m.Parse,
+		ValidId: func(id string) bool { return m.Parse(id) == id },
+		Find:    fs.Find,
+	}
+}
+
+// Parse finds the account in a profile or post URL, or a handle like
+// @user@instance, on one of m's instances.
+func (m *MastodonStorage) Parse(urlOrId string) string {
+	var user, inst string
+	if u := mastodonUrlR.FindStringSubmatch(urlOrId); u != nil {
+		user, inst = u[2], u[1]
+	} else if h := mastodonHandleR.FindStringSubmatch(urlOrId); h != nil {
+		user, inst = h[1], h[2]
+	}
+	inst = strings.ToLower(inst)
+	if !m.instances[inst] {
+		return ""
+	}
+	return user + "@" + inst
+}
+
+func (m *MastodonStorage) Find(id string) (Feed, error) {
+	mastodonFinds.Inc(1)
+	feed, err := m.find(id)
+	if err != nil {
+		mastodonFindFailures.Inc(1)
+	}
+	return feed, err
+}
+
+func (m *MastodonStorage) find(id string) (Feed, error) {
+	if m.Parse(id) != id {
+		return nil, &googleapi.Error{Code: http.StatusNotFound, Message: "not an account on a known Mastodon instance: " + id}
+	}
+	i := strings.LastIndex(id, "@")
+	user, inst := id[:i], id[i+1:]
+
+	acct := &mastodonAccount{}
+	err := m.get(inst, "/api/v1/accounts/lookup", url.Values{"acct": {user}}, acct)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []*mastodonStatus
+	q := url.Values{"exclude_replies": {"true"}, "limit": {fmt.Sprint(m.entries)}}
+	err = m.get(inst, "/api/v1/accounts/"+url.PathEscape(acct.Id)+"/statuses", q, &statuses)
+	if err != nil {
+		return nil, err
+	}
+	feed := &MastodonFeed{id: id, account: acct}
+	for _, s := range statuses {
+		// Unlisted and private posts aren't for feeds.
+		if s.Visibility == "" || s.Visibility == "public" {
+			feed.statuses = append(feed.statuses, s)
+		}
+	}
+	return feed, nil
+}
+
+func (m *MastodonStorage) get(inst, path string, q url.Values, v interface{}) error {
+	u := "https://" + inst + path + "?" + q.Encode()
+	m.lg.Printf("Mastodon: %s", u)
+	res, err := m.client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return &googleapi.Error{Code: res.StatusCode, Message: "Mastodon answered " + res.Status + " for " + u}
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+type mastodonAccount struct {
+	Id          string `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	URL         string `json:"url"`
+}
+
+// Name is the account's display name, or its username without one.
+func (a *mastodonAccount) Name() string {
+	if a.DisplayName != "" {
+		return a.DisplayName
+	}
+	return a.Username
+}
+
+type mastodonStatus struct {
+	Id           string           `json:"id"`
+	URI          string           `json:"uri"`
+	URL          string           `json:"url"`
+	CreatedAt    string           `json:"created_at"`
+	EditedAt     string           `json:"edited_at"`
+	Content      string           `json:"content"`
+	SpoilerText  string           `json:"spoiler_text"`
+	Visibility   string           `json:"visibility"`
+	RepliesCount int64            `json:"replies_count"`
+	Account      mastodonAccount  `json:"account"`
+	Reblog       *mastodonStatus  `json:"reblog"`
+	Media        []*mastodonMedia `json:"media_attachments"`
+	Card         *mastodonCard    `json:"card"`
+}
+
+type mastodonMedia struct {
+	Id          string `json:"id"`
+	Type        string `json:"type"`
+	URL         string `json:"url"`
+	PreviewURL  string `json:"preview_url"`
+	Description string `json:"description"`
+	Meta        struct {
+		Original struct {
+			Width  int64 `json:"width"`
+			Height int64 `json:"height"`
+		} `json:"original"`
+	} `json:"meta"`
+}
+
+type mastodonCard struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Image       string `json:"image"`
+}
+
+// MastodonFeed is the Feed of a Mastodon account's newest public posts.
+// Its ActorId is the account's USER@INSTANCE.
+type MastodonFeed struct {
+	id       string
+	account  *mastodonAccount
+	statuses []*mastodonStatus
+}
+
+func (f *MastodonFeed) Title() string {
+	return f.ActorName()
+}
+
+func (f *MastodonFeed) Id() string {
+	return "plus2rss-mastodon-" + f.id
+}
+
+// Updated is when the newest post was made, which is as close as Mastodon
+// comes to saying.
+func (f *MastodonFeed) Updated() string {
+	var updated string
+	for _, s := range f.statuses {
+		if updated == "" || parseTime(s.CreatedAt).After(parseTime(updated)) {
+			updated = s.CreatedAt
+		}
+	}
+	return updated
+}
+
+func (f *MastodonFeed) Items() []Activity {
+	acts := make([]Activity, len(f.statuses))
+	for i, s := range f.statuses {
+		acts[i] = &MastodonActivity{s}
+	}
+	return acts
+}
+
+func (f *MastodonFeed) ActorName() string {
+	return f.account.Name()
+}
+
+func (f *MastodonFeed) ActorId() string {
+	return f.id
+}
+
+func (f *MastodonFeed) Network() string {
+	return "Mastodon"
+}
+
+// MastodonActivity is a Mastodon post seen as an Activity. Boosts are
+// reshares without anything added to them. Posts' content warnings are
+// their titles.
+type MastodonActivity struct {
+	s *mastodonStatus
+}
+
+// shown is the post whose content is shown: the boosted one, for boosts.
+func (a *MastodonActivity) shown() *mastodonStatus {
+	if a.s.Reblog != nil {
+		return a.s.Reblog
+	}
+	return a.s
+}
+
+func (a *MastodonActivity) Verb() string {
+	if a.IsReshare() {
+		return "share"
+	}
+	return "post"
+}
+
+func (a *MastodonActivity) Updated() string {
+	if a.s.EditedAt != "" {
+		return a.s.EditedAt
+	}
+	return a.s.CreatedAt
+}
+
+func (a *MastodonActivity) Published() string {
+	return a.s.CreatedAt
+}
+
+func (a *MastodonActivity) Content() string {
+	return a.shown().Content
+}
+
+func (a *MastodonActivity) ContentHTML() string {
+	return sanitizeHTML(a.Content())
+}
+
+func (a *MastodonActivity) ContentText() string {
+	return htmlText(a.Content())
+}
+
+func (a *MastodonActivity) Title() string {
+	return a.shown().SpoilerText
+}
+
+func (a *MastodonActivity) Id() string {
+	return a.s.Id
+}
+
+// URL is the post's page, or for boosts, which have none, its URI.
+func (a *MastodonActivity) URL() string {
+	if a.s.URL != "" {
+		return a.s.URL
+	}
+	return a.s.URI
+}
+
+func (a *MastodonActivity) ActorName() string {
+	return a.s.Account.Name()
+}
+
+func (a *MastodonActivity) ActorURL() string {
+	return a.s.Account.URL
+}
+
+func (a *MastodonActivity) Attachments() []Attachment {
+	s := a.shown()
+	var as []Attachment
+	for _, m := range s.Media {
+		as = append(as, newMastodonMediaAttachment(m))
+	}
+	if c := s.Card; c != nil && c.URL != "" {
+		at := &simpleAttachment{objectType: "article", displayName: c.Title, content: c.Description, url: c.URL}
+		if c.Image != "" {
+			at.image = &simpleImage{url: c.Image}
+		}
+		as = append(as, at)
+	}
+	return as
+}
+
+func (a *MastodonActivity) IsReshare() bool {
+	return a.s.Reblog != nil
+}
+
+func (a *MastodonActivity) Annotation() string {
+	return ""
+}
+
+func (a *MastodonActivity) OriginalActorName() string {
+	if !a.IsReshare() {
+		return ""
+	}
+	return a.s.Reblog.Account.Name()
+}
+
+func (a *MastodonActivity) OriginalActorURL() string {
+	if !a.IsReshare() {
+		return ""
+	}
+	return a.s.Reblog.Account.URL
+}
+
+func (a *MastodonActivity) OriginalURL() string {
+	if !a.IsReshare() {
+		return ""
+	}
+	return a.s.Reblog.URL
+}
+
+func (a *MastodonActivity) Replies() int64 {
+	return a.shown().RepliesCount
+}
+
+func (a *MastodonActivity) InReplyTo() string {
+	return ""
+}
+
+// CommentsId is always empty, since there's no feed of Mastodon replies.
+func (a *MastodonActivity) CommentsId() string {
+	return ""
+}
+
+func newMastodonMediaAttachment(m *mastodonMedia) *simpleAttachment {
+	at := &simpleAttachment{displayName: m.Description, id: m.Id, url: m.URL}
+	if m.PreviewURL != "" {
+		at.image = &simpleImage{url: m.PreviewURL}
+	}
+	switch m.Type {
+	case "image":
+		at.objectType = "photo"
+		at.fullImage = &simpleImage{url: m.URL, width: m.Meta.Original.Width, height: m.Meta.Original.Height}
+	case "video", "gifv":
+		at.objectType = "video"
+	case "audio":
+		at.objectType = "audio"
+	default:
+		at.objectType = "article"
+	}
+	return at
+}
+
+// simpleAttachment is an Attachment for sources that aren't Google+, made
+// of just what they have.
+type simpleAttachment struct {
+	objectType  string
+	displayName string
+	id          string
+	content     string
+	url         string
+	image       Image
+	fullImage   Image
+}
+
+func (a *simpleAttachment) ObjectType() string {
+	return a.objectType
+}
+func (a *simpleAttachment) DisplayName() string {
+	return a.displayName
+}
+func (a *simpleAttachment) Id() string {
+	return a.id
+}
+func (a *simpleAttachment) Content() string {
+	return a.content
+}
+func (a *simpleAttachment) URL() string {
+	return a.url
+}
+func (a *simpleAttachment) Image() Image {
+	return a.image
+}
+func (a *simpleAttachment) FullImage() Image {
+	return a.fullImage
+}
+func (a *simpleAttachment) Embed() Embed {
+	return nil
+}
+func (a *simpleAttachment) Thumbnails() []Thumbnail {
+	return nil
+}
+func (a *simpleAttachment) IsVideo() bool {
+	return a.objectType == "video"
+}
+func (a *simpleAttachment) IsPhoto() bool {
+	return a.objectType == "photo"
+}
+func (a *simpleAttachment) IsArticle() bool {
+	return a.objectType == "article"
+}
+func (a *simpleAttachment) IsAlbum() bool {
+	return false
+}
+func (a *simpleAttachment) IsEvent() bool {
+	return false
+}
+func (a *simpleAttachment) IsAudio() bool {
+	return a.objectType == "audio"
+}
+
+type simpleImage struct {
+	url           string
+	typ           string
+	width, height int64
+}
+
+func (i *simpleImage) URL() string {
+	return i.url
+}
+func (i *simpleImage) Type() string {
+	return i.typ
+}
+func (i *simpleImage) Height() int64 {
+	return i.height
+}
+func (i *simpleImage) Width() int64 {
+	return i.width
+}
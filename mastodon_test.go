@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const mastodonAccountJSON = `{"id": "109", "username": "alice", "acct": "alice", "display_name": "Alice", "url": "https://mastodon.example/@alice"}`
+
+const mastodonStatusesJSON = `[
+ {"id": "3", "created_at": "2023-03-01T10:00:00.000Z", "url": "https://mastodon.example/@alice/3", "visibility": "public",
+  "content": "<p>Photos from the trip.</p>", "replies_count": 2, "account": {"id": "109", "username": "alice", "display_name": "Alice", "url": "https://mastodon.example/@alice"},
+  "media_attachments": [
+   {"id": "m1", "type": "image", "url": "https://files.mastodon.example/m1.jpg", "preview_url": "https://files.mastodon.example/m1_small.jpg", "description": "A lake",
+    "meta": {"original": {"width": 1200, "height": 800}}},
+   {"id": "m2", "type": "video", "url": "https://files.mastodon.example/m2.mp4", "preview_url": "https://files.mastodon.example/m2.png"}
+  ]},
+ {"id": "2", "created_at": "2023-02-01T10:00:00.000Z", "uri": "https://mastodon.example/users/alice/statuses/2/activity", "visibility": "public",
+  "content": "", "account": {"id": "109", "username": "alice", "display_name": "Alice"},
+  "reblog": {"id": "77", "created_at": "2023-01-31T10:00:00.000Z", "url": "https://other.example/@bob/77", "content": "<p>Tabs, not spaces.</p>",
+   "account": {"id": "5", "username": "bob", "display_name": "", "url": "https://other.example/@bob"},
+   "card": {"url": "https://blog.example/tabs", "title": "On tabs", "image": "https://blog.example/tabs.png"}}},
+ {"id": "1", "created_at": "2023-01-01T10:00:00.000Z", "url": "https://mastodon.example/@alice/1", "visibility": "unlisted",
+  "content": "<p>Not for feeds.</p>", "account": {"id": "109", "username": "alice", "display_name": "Alice"}}
+]`
+
+func mastodonResponse(code int, body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	rr.Code = code
+	rr.Body.WriteString(body)
+	return rr
+}
+
+func testMastodon(t *testing.T) *MastodonStorage {
+	tr := &FakeClientTransport{}
+	add := func(rawurl string, rr *httptest.ResponseRecorder) {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			t.Fatalf("url.Parse: %s", err)
+		}
+		tr.Add(u, "GET", rr)
+	}
+	add("https://mastodon.example/api/v1/accounts/lookup?acct=alice", mastodonResponse(http.StatusOK, mastodonAccountJSON))
+	add("https://mastodon.example/api/v1/accounts/109/statuses?exclude_replies=true&limit=20", mastodonResponse(http.StatusOK, mastodonStatusesJSON))
+	add("https://mastodon.example/api/v1/accounts/lookup?acct=nobody", mastodonResponse(http.StatusNotFound, `{"error": "Record not found"}`))
+	return NewMastodonStorage(&http.Client{Transport: tr}, []string{" Mastodon.Example", ""}, 20, nullLog())
+}
+
+func TestMastodonParse(t *testing.T) {
+	m := testMastodon(t)
+	ids := map[string]string{
+		"https://mastodon.example/@alice":         "alice@mastodon.example",
+		"https://Mastodon.Example/@alice/":        "alice@mastodon.example",
+		"https://mastodon.example/@alice/1234":    "alice@mastodon.example",
+		"@alice@mastodon.example":                 "alice@mastodon.example",
+		"alice@mastodon.example":                  "alice@mastodon.example",
+		"alice@elsewhere.example":                 "",
+		"https://elsewhere.example/@alice":        "",
+		"https://mastodon.example/@alice/1/media": "",
+		"116810148281701144465":                   "",
+	}
+	for in, id := range ids {
+		if got := m.Parse(in); got != id {
+			t.Errorf("Parse(%q): want %q, got %q", in, id, got)
+		}
+	}
+}
+
+func TestMastodonFind(t *testing.T) {
+	feed, err := testMastodon(t).Find("alice@mastodon.example")
+	if err != nil {
+		t.Fatalf("Find: %s", err)
+	}
+	if feed.Id() != "plus2rss-mastodon-alice@mastodon.example" || feed.ActorName() != "Alice" || feed.Updated() != "2023-03-01T10:00:00.000Z" {
+		t.Errorf("got feed %q of %q updated %q", feed.Id(), feed.ActorName(), feed.Updated())
+	}
+	items := feed.Items()
+	if len(items) != 2 {
+		t.Fatalf("want the 2 public posts, got %d", len(items))
+	}
+
+	post := items[0]
+	if post.Replies() != 2 || post.CommentsId() != "" || post.ContentText() != "Photos from the trip." {
+		t.Errorf("post: got %d replies, comments id %q, text %q", post.Replies(), post.CommentsId(), post.ContentText())
+	}
+	as := post.Attachments()
+	if len(as) != 2 || !as[0].IsPhoto() || !as[1].IsVideo() {
+		t.Fatalf("want a photo and a video, got %+v", as)
+	}
+	if img := largestImage(as[0]); img.URL() != "https://files.mastodon.example/m1.jpg" || img.Width() != 1200 {
+		t.Errorf("photo: got %q %d wide", img.URL(), img.Width())
+	}
+	if v := NewVideo(as[1]); v.FileURL != "https://files.mastodon.example/m2.mp4" || v.Poster != "https://files.mastodon.example/m2.png" {
+		t.Errorf("video: got file %q, poster %q", v.FileURL, v.Poster)
+	}
+
+	boost := items[1]
+	if !boost.IsReshare() || boost.OriginalActorName() != "bob" || boost.Content() != "<p>Tabs, not spaces.</p>" {
+		t.Errorf("boost: got %q by %q", boost.Content(), boost.OriginalActorName())
+	}
+	if boost.URL() != "https://mastodon.example/users/alice/statuses/2/activity" {
+		t.Errorf("boost URL: got %q", boost.URL())
+	}
+	if as := boost.Attachments(); len(as) != 1 || !as[0].IsArticle() || as[0].Image() == nil {
+		t.Errorf("want the boosted post's card, got %+v", as)
+	}
+}
+
+func TestMastodonNotFound(t *testing.T) {
+	m := testMastodon(t)
+	for _, id := range []string{"nobody@mastodon.example", "alice@elsewhere.example"} {
+		if _, err := m.Find(id); !isNotFound(err) {
+			t.Errorf("%s: want not found, got %v", id, err)
+		}
+	}
+}
+
+func TestMastodonFrontend(t *testing.T) {
+	fr := fixtureRetriever(t)
+	m := testMastodon(t)
+	sources := NewPlusSources(fr)
+	sources.Register(m.SourceType(m))
+	h := NewFrontendMux(fr, fr, sources, NewCachingSearchStorage(fr, time.Minute, time.Minute, 10, 10), fr, nil, 10, FeedOptions{TitlePolicy: TitleDerived, TitleLength: 80}, testHost, "./templates")
+
+	w := get(h, "/mastodon/alice@mastodon.example", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d: %s", w.Code, w.Body)
+	}
+	doc := &atomDoc{}
+	if err := xml.Unmarshal(w.Body.Bytes(), doc); err != nil {
+		t.Fatalf("Atom didn't parse: %s", err)
+	}
+	if doc.Title != "Alice on Mastodon" || len(doc.Entries) != 2 {
+		t.Errorf("got %q with %d entries", doc.Title, len(doc.Entries))
+	}
+	if strings.Contains(w.Body.String(), `rel="replies"`) {
+		t.Errorf("Mastodon posts have no comments feed to link to")
+	}
+
+	// Filtering the posts keeps the feed on Mastodon.
+	w = get(h, "/mastodon/alice@mastodon.example?has=photo", nil)
+	doc = &atomDoc{}
+	if err := xml.Unmarshal(w.Body.Bytes(), doc); err != nil {
+		t.Fatalf("Atom didn't parse: %s", err)
+	}
+	if doc.Title != "Alice on Mastodon" || len(doc.Entries) != 1 {
+		t.Errorf("filtered: got %q with %d entries", doc.Title, len(doc.Entries))
+	}
+
+	codes := map[string]int{
+		"/mastodon/nobody@mastodon.example": http.StatusNotFound,
+		"/mastodon/alice@elsewhere.example": http.StatusNotFound,
+		"/mastodon/alice":                   http.StatusNotFound,
+		"/u/116810148281701144465":          http.StatusOK,
+	}
+	for p, code := range codes {
+		if w := get(h, p, nil); w.Code != code {
+			t.Errorf("%s: want %d, got %d", p, code, w.Code)
+		}
+	}
+
+	r, _ := http.NewRequest("POST", "/plus/enqueue", strings.NewReader("url_or_user_id="+url.QueryEscape("https://mastodon.example/@alice")))
+	r.Host = testHost
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if loc := w.Header().Get("Location"); loc != "/mastodon/alice@mastodon.example" {
+		t.Errorf("enqueue: want a redirect to the Mastodon feed, got %d %q", w.Code, loc)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNoSuchList     = errors.New("no such list")
+	ErrTooManyMembers = errors.New("too many users to merge")
+)
+
+// MergedList is a list of users whose posts are served as one feed, as
+// defined in the -listsFile.
+type MergedList struct {
+	Title string   `json:"title"`
+	Users []string `json:"users"`
+}
+
+// LoadLists reads the lists in the JSON file at path, which maps list ids
+// to MergedLists:
+//
+//	{"team": {"title": "The team", "users": ["116810148281701144465"]}}
+//
+// A list of more than maxMembers users is an error, since its feed could
+// never be merged.
+func LoadLists(path string, maxMembers int) (map[string]*MergedList, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lists := make(map[string]*MergedList)
+	err = json.Unmarshal(b, &lists)
+	if err != nil {
+		return nil, err
+	}
+	for id, l := range lists {
+		if n := len(dedupe(l.Users)); n > maxMembers {
+			return nil, fmt.Errorf("list %q has %d users, more than %d", id, n, maxMembers)
+		}
+	}
+	return lists, nil
+}
+
+// Merger makes one feed out of the feeds of several users. It finds at
+// most parallelism of them at once, will merge no more than maxMembers,
+// and keeps the newest entries posts of them all.
+type Merger struct {
+	store       FeedStorage
+	lists       map[string]*MergedList
+	parallelism int
+	maxMembers  int
+	entries     int
+	lg          *log.Logger
+}
+
+func NewMerger(fs FeedStorage, lists map[string]*MergedList, parallelism, maxMembers, entries int, lg *log.Logger) *Merger {
+	if lists == nil {
+		lists = make(map[string]*MergedList)
+	}
+	return &Merger{fs, lists, parallelism, maxMembers, entries, lg}
+}
+
+// FindList merges the feeds of the users in the list with the given id.
+func (m *Merger) FindList(listId string) (Feed, error) {
+	l, ok := m.lists[listId]
+	if !ok {
+		return nil, ErrNoSuchList
+	}
+	return m.merge("plus2rss-m-"+listId, l.Title, l.Users)
+}
+
+// FindUsers merges the feeds of userIds, in whatever order they're given.
+// Unlike lists, whose sizes LoadLists checks, they may be too many.
+func (m *Merger) FindUsers(userIds []string) (Feed, error) {
+	ids := dedupe(userIds)
+	if len(ids) > m.maxMembers {
+		return nil, ErrTooManyMembers
+	}
+	sort.Strings(ids)
+	return m.merge("plus2rss-m-"+strings.Join(ids, ","), "", ids)
+}
+
+// merge finds each user's feed and interleaves their posts, newest first.
+// Users that can't be found are left out, unless none can be, in which case
+// the first error is returned.
+func (m *Merger) merge(id, title string, userIds []string) (Feed, error) {
+	userIds = dedupe(userIds)
+	if len(userIds) == 0 {
+		return nil, ErrNoSuchList
+	}
+	mergedFinds.Inc(1)
+
+	feeds := make([]Feed, len(userIds))
+	errs := make([]error, len(userIds))
+	sem := make(chan bool, m.parallelism)
+	var wg sync.WaitGroup
+	for i, userId := range userIds {
+		wg.Add(1)
+		sem <- true
+		go func(i int, userId string) {
+			defer wg.Done()
+			feeds[i], errs[i] = m.store.Find(userId)
+			<-sem
+		}(i, userId)
+	}
+	wg.Wait()
+
+	mf := &MergedFeed{id: id, title: title}
+	var firstErr error
+	for i, feed := range feeds {
+		if errs[i] != nil {
+			mergeMemberErrors.Inc(1)
+			if !isNotFound(errs[i]) {
+				m.lg.Printf("merging %s: %s: %s", id, userIds[i], errs[i])
+			}
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		mf.members = append(mf.members, feed)
+	}
+	if len(mf.members) == 0 {
+		return nil, firstErr
+	}
+	mf.items = interleave(mf.members, m.entries)
+	return mf, nil
+}
+
+// interleave is the newest max posts of all the feeds.
+func interleave(feeds []Feed, max int) []Activity {
+	var items []Activity
+	for _, f := range feeds {
+		items = append(items, f.Items()...)
+	}
+	sort.Stable(activitiesByPublished(items))
+	if len(items) > max {
+		items = items[:max]
+	}
+	return items
+}
+
+func dedupe(ss []string) []string {
+	seen := make(map[string]bool, len(ss))
+	var out []string
+	for _, s := range ss {
+		if s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// MergedFeed is the posts of several users' feeds as one Feed. Its
+// ActorName is the list's title, if it has one, and it has no ActorId.
+type MergedFeed struct {
+	id      string
+	title   string
+	members []Feed
+	items   []Activity
+}
+
+func (mf *MergedFeed) Title() string {
+	return mf.ActorName()
+}
+
+func (mf *MergedFeed) Id() string {
+	return mf.id
+}
+
+// Updated is the latest of its members' Updated times.
+func (mf *MergedFeed) Updated() string {
+	var updated string
+	for _, f := range mf.members {
+		if updated == "" || parseTime(f.Updated()).After(parseTime(updated)) {
+			updated = f.Updated()
+		}
+	}
+	return updated
+}
+
+func (mf *MergedFeed) Items() []Activity {
+	return mf.items
+}
+
+// ActorName is the list's title or, without one, the names of the users in
+// it.
+func (mf *MergedFeed) ActorName() string {
+	if mf.title != "" {
+		return mf.title
+	}
+	names := make([]string, len(mf.members))
+	for i, f := range mf.members {
+		names[i] = f.ActorName()
+	}
+	return strings.Join(names, ", ")
+}
+
+func (mf *MergedFeed) ActorId() string {
+	return ""
+}
+
+type activitiesByPublished []Activity
+
+func (b activitiesByPublished) Len() int {
+	return len(b)
+}
+
+func (b activitiesByPublished) Less(i, j int) bool {
+	return parseTime(b[i].Published()).After(parseTime(b[j].Published()))
+}
+
+func (b activitiesByPublished) Swap(i, j int) {
+	b[i], b[j] = b[j], b[i]
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	plus "google.golang.org/api/plus/v1"
+)
+
+// userStorage is a FeedStorage of a fixed set of users, which keeps track
+// of how many Finds it has going at once.
+type userStorage struct {
+	users map[string][]*plus.Activity
+
+	mu      sync.Mutex
+	running int
+	most    int
+}
+
+func (s *userStorage) Find(userId string) (Feed, error) {
+	s.mu.Lock()
+	s.running++
+	if s.running > s.most {
+		s.most = s.running
+	}
+	s.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	s.mu.Lock()
+	s.running--
+	s.mu.Unlock()
+
+	items, ok := s.users[userId]
+	if !ok {
+		return nil, &googleapi.Error{Code: 404}
+	}
+	p := &plus.Person{Id: userId, DisplayName: "User " + userId}
+	return &ActorFeed{p, &plus.ActivityFeed{Items: items, Updated: items[0].Updated}}, nil
+}
+
+func TestMergeInterleaves(t *testing.T) {
+	us := &userStorage{users: map[string][]*plus.Activity{
+		"1": {
+			act("1c", "2012-09-05T00:00:00Z", "2012-09-05T00:00:00Z"),
+			act("1b", "2012-09-03T00:00:00Z", "2012-09-03T00:00:00Z"),
+			act("1a", "2012-09-01T00:00:00Z", "2012-09-01T00:00:00Z"),
+		},
+		"2": {
+			act("2b", "2012-09-04T00:00:00Z", "2012-09-06T00:00:00Z"),
+			act("2a", "2012-09-02T00:00:00Z", "2012-09-02T00:00:00Z"),
+		},
+	}}
+	m := NewMerger(us, nil, 2, 10, 4, nullLog())
+	feed, err := m.FindUsers([]string{"2", "1", "2", "404"})
+	if err != nil {
+		t.Fatalf("FindUsers: %s", err)
+	}
+	var ids []string
+	for _, a := range feed.Items() {
+		ids = append(ids, a.Id())
+	}
+	want := []string{"1c", "2b", "1b", "2a"}
+	if len(ids) != len(want) {
+		t.Fatalf("want %v, got %v", want, ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("want %v, got %v", want, ids)
+		}
+	}
+	if feed.Id() != "plus2rss-m-1,2,404" {
+		t.Errorf("Id: got %q", feed.Id())
+	}
+	if feed.ActorName() != "User 1, User 2" {
+		t.Errorf("ActorName: got %q", feed.ActorName())
+	}
+	if feed.Updated() != "2012-09-06T00:00:00Z" {
+		t.Errorf("Updated: got %q", feed.Updated())
+	}
+}
+
+func TestMergeBoundsParallelism(t *testing.T) {
+	us := &userStorage{users: make(map[string][]*plus.Activity)}
+	var userIds []string
+	for _, id := range []string{"1", "2", "3", "4", "5", "6", "7", "8"} {
+		us.users[id] = []*plus.Activity{act(id, "2012-09-01T00:00:00Z", "2012-09-01T00:00:00Z")}
+		userIds = append(userIds, id)
+	}
+	m := NewMerger(us, nil, 3, 10, 100, nullLog())
+	feed, err := m.FindUsers(userIds)
+	if err != nil {
+		t.Fatalf("FindUsers: %s", err)
+	}
+	if len(feed.Items()) != 8 {
+		t.Errorf("want 8 items, got %d", len(feed.Items()))
+	}
+	if us.most > 3 {
+		t.Errorf("want at most 3 Finds at once, got %d", us.most)
+	}
+
+	_, err = NewMerger(us, nil, 3, 7, 100, nullLog()).FindUsers(userIds)
+	if err != ErrTooManyMembers {
+		t.Errorf("8 users with a max of 7: want ErrTooManyMembers, got %v", err)
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	m := NewMerger(&userStorage{}, nil, 2, 10, 100, nullLog())
+	if _, err := m.FindUsers([]string{"404", "405"}); !isNotFound(err) {
+		t.Errorf("all users missing: want a not found error, got %v", err)
+	}
+	if _, err := m.FindUsers(nil); err != ErrNoSuchList {
+		t.Errorf("no users: want ErrNoSuchList, got %v", err)
+	}
+	if _, err := m.FindList("nope"); err != ErrNoSuchList {
+		t.Errorf("unknown list: want ErrNoSuchList, got %v", err)
+	}
+
+	m = NewMerger(&countingStorage{err: errors.New("boom")}, nil, 2, 10, 100, nullLog())
+	if _, err := m.FindUsers([]string{"1"}); err == nil || isNotFound(err) {
+		t.Errorf("failing storage: want its error, got %v", err)
+	}
+}
+
+func TestLoadLists(t *testing.T) {
+	f, err := ioutil.TempFile("", "plus2rss-lists")
+	if err != nil {
+		t.Fatalf("TempFile: %s", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"team": {"title": "The team", "users": ["1", "2"]}}`)
+	f.Close()
+
+	lists, err := LoadLists(f.Name(), 2)
+	if err != nil {
+		t.Fatalf("LoadLists: %s", err)
+	}
+	l := lists["team"]
+	if l == nil || l.Title != "The team" || len(l.Users) != 2 {
+		t.Errorf("team list: got %+v", l)
+	}
+
+	if _, err := LoadLists(f.Name(), 1); err == nil {
+		t.Errorf("2 users with a max of 1: want an error")
+	}
+}
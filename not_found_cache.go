@@ -0,0 +1,61 @@
+package main
+
+import (
+	"time"
+)
+
+// NotFoundCachingFeedStorage wraps another FeedStorage and remembers which
+// user ids it said don't exist, for ttl, so that polls of deleted or mistyped
+// users don't cost a trip to Google+. At most maxEntries ids are remembered.
+type NotFoundCachingFeedStorage struct {
+	store FeedStorage
+	cache *ttlCache
+}
+
+func NewNotFoundCachingFeedStorage(fs FeedStorage, ttl time.Duration, maxEntries int) *NotFoundCachingFeedStorage {
+	return &NotFoundCachingFeedStorage{fs, newTTLCache(ttl, maxEntries, notFoundCacheEvictions)}
+}
+
+func (n *NotFoundCachingFeedStorage) Find(userId string) (Feed, error) {
+	return n.find(userId, n.store.Find)
+}
+
+// Paged wraps pfs so that it shares the user ids n remembers weren't found.
+func (n *NotFoundCachingFeedStorage) Paged(pfs PagedFeedStorage) PagedFeedStorage {
+	return &notFoundPagedStorage{n, pfs}
+}
+
+func (n *NotFoundCachingFeedStorage) find(userId string, find func(string) (Feed, error)) (Feed, error) {
+	if v, ok := n.cache.Get(userId); ok {
+		notFoundCacheHits.Inc(1)
+		return nil, v.(error)
+	}
+	notFoundCacheMisses.Inc(1)
+	feed, err := find(userId)
+	if isNotFound(err) {
+		notFoundCacheStores.Inc(1)
+		n.cache.Add(userId, err)
+	}
+	return feed, err
+}
+
+// Clear forgets that the user id was not found, and reports whether it had
+// been.
+func (n *NotFoundCachingFeedStorage) Clear(userId string) bool {
+	ok := n.cache.Remove(userId)
+	if ok {
+		notFoundCacheClears.Inc(1)
+	}
+	return ok
+}
+
+type notFoundPagedStorage struct {
+	nf    *NotFoundCachingFeedStorage
+	store PagedFeedStorage
+}
+
+func (p *notFoundPagedStorage) FindPages(userId string, limit PageLimit) (Feed, error) {
+	return p.nf.find(userId, func(userId string) (Feed, error) {
+		return p.store.FindPages(userId, limit)
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestNotFoundCached(t *testing.T) {
+	cs := &countingStorage{err: &googleapi.Error{Code: http.StatusNotFound}}
+	nfs := NewNotFoundCachingFeedStorage(cs, time.Hour, 10)
+
+	for i := 0; i < 3; i++ {
+		_, err := nfs.Find("444")
+		if !isNotFound(err) {
+			t.Fatalf("want a 404 error, got %v", err)
+		}
+	}
+	if cs.Calls("444") != 1 {
+		t.Errorf("upstream calls: want 1, got %d", cs.Calls("444"))
+	}
+
+	h := &ClearNotFoundHandler{nfs}
+	body := strings.NewReader(url.Values{"user_id": {"444"}}.Encode())
+	r, _ := http.NewRequest("POST", "/not_found/clear", body)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("clear: want status 200, got %d", w.Code)
+	}
+
+	nfs.Find("444")
+	if cs.Calls("444") != 2 {
+		t.Errorf("after clear, upstream calls: want 2, got %d", cs.Calls("444"))
+	}
+}
+
+func TestNotFoundIgnoresOtherErrors(t *testing.T) {
+	cs := &countingStorage{err: &googleapi.Error{Code: http.StatusInternalServerError}}
+	nfs := NewNotFoundCachingFeedStorage(cs, time.Hour, 10)
+	nfs.Find("1")
+	nfs.Find("1")
+	if cs.Calls("1") != 2 {
+		t.Errorf("upstream calls: want 2, got %d", cs.Calls("1"))
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxFilterLen is the longest include or exclude pattern a feed may have.
+const maxFilterLen = 256
+
+// FeedOptions are the choices a request can make about what goes in a feed.
+type FeedOptions struct {
+	ExcludeReshares bool
+	MediaRSS        bool
+	TitlePolicy     string
+	TitleLength     int
+
+	// The filters. Include and Exclude match an activity's title or text,
+	// Verb keeps only activities with that verb, and Has keeps only those
+	// with at least one of the kinds of attachment in it.
+	Include *regexp.Regexp
+	Exclude *regexp.Regexp
+	Verb    string
+	Has     []string
+}
+
+// feedOptions parses the FeedOptions from r's query parameters, starting
+// from defaults. With reshares=false, posts that are reshares are left out.
+// With media=mrss, photos and videos are Media RSS media:content elements
+// instead of enclosures; media=enclosure is the default. title=api or
+// title=derived picks the title policy.
+//
+// The filters are include=REGEXP and exclude=REGEXP, verb=post or
+// verb=share, and has=photo, has=article or has=video, which may be
+// combined as has=photo,video.
+func feedOptions(r *http.Request, defaults FeedOptions) (FeedOptions, error) {
+	opts := defaults
+	if v := r.FormValue("reshares"); v != "" {
+		reshares, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.ExcludeReshares = !reshares
+	}
+	switch r.FormValue("media") {
+	case "", "enclosure":
+	case "mrss":
+		opts.MediaRSS = true
+	default:
+		return opts, errors.New("unknown media option")
+	}
+	if v := r.FormValue("title"); v != "" {
+		if !validTitlePolicy(v) {
+			return opts, errors.New("unknown title policy")
+		}
+		opts.TitlePolicy = v
+	}
+
+	var err error
+	opts.Include, err = filterRegexp(r.FormValue("include"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Exclude, err = filterRegexp(r.FormValue("exclude"))
+	if err != nil {
+		return opts, err
+	}
+	switch v := r.FormValue("verb"); v {
+	case "", "post", "share":
+		opts.Verb = v
+	default:
+		return opts, errors.New("unknown verb")
+	}
+	opts.Has = nil
+	if v := r.FormValue("has"); v != "" {
+		for _, kind := range strings.Split(v, ",") {
+			switch kind {
+			case "photo", "article", "video":
+				opts.Has = append(opts.Has, kind)
+			default:
+				return opts, errors.New("unknown attachment kind")
+			}
+		}
+		sort.Strings(opts.Has)
+	}
+	return opts, nil
+}
+
+func filterRegexp(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	if len(expr) > maxFilterLen {
+		return nil, errors.New("filter too long")
+	}
+	return regexp.Compile(expr)
+}
+
+// Apply returns the feed with the activities the options exclude removed.
+// A filtered feed has an Id of its own, so that feed readers don't mistake
+// it for the unfiltered one.
+func (o FeedOptions) Apply(feed Feed) Feed {
+	key := o.filterKey()
+	if key == "" {
+		return feed
+	}
+	var items []Activity
+	for _, a := range feed.Items() {
+		if o.keep(a) {
+			items = append(items, a)
+		}
+	}
+	h := sha1.Sum([]byte(key))
+	return &filteredFeed{feed, feed.Id() + "-" + hex.EncodeToString(h[:8]), items}
+}
+
+// filterKey is the options' filters written out the same way every time,
+// or "" if there are none.
+func (o FeedOptions) filterKey() string {
+	v := url.Values{}
+	if o.ExcludeReshares {
+		v.Set("reshares", "false")
+	}
+	if o.Include != nil {
+		v.Set("include", o.Include.String())
+	}
+	if o.Exclude != nil {
+		v.Set("exclude", o.Exclude.String())
+	}
+	if o.Verb != "" {
+		v.Set("verb", o.Verb)
+	}
+	if len(o.Has) > 0 {
+		v.Set("has", strings.Join(o.Has, ","))
+	}
+	return v.Encode()
+}
+
+func (o FeedOptions) keep(a Activity) bool {
+	if o.ExcludeReshares && a.IsReshare() {
+		return false
+	}
+	if o.Verb != "" && a.Verb() != o.Verb {
+		return false
+	}
+	if o.Include != nil && !matchesActivity(o.Include, a) {
+		return false
+	}
+	if o.Exclude != nil && matchesActivity(o.Exclude, a) {
+		return false
+	}
+	if len(o.Has) > 0 && !hasAttachment(a, o.Has) {
+		return false
+	}
+	return true
+}
+
+// matchesActivity reports whether re matches a's title, its text, or, for
+// reshares, what the resharer said about it.
+func matchesActivity(re *regexp.Regexp, a Activity) bool {
+	return re.MatchString(a.Title()) ||
+		re.MatchString(a.ContentText()) ||
+		(a.IsReshare() && re.MatchString(htmlText(a.Annotation())))
+}
+
+// hasAttachment reports whether a has any of the kinds of attachment.
+func hasAttachment(a Activity, kinds []string) bool {
+	for _, at := range a.Attachments() {
+		for _, kind := range kinds {
+			switch {
+			case kind == "photo" && at.IsPhoto(),
+				kind == "article" && at.IsArticle(),
+				kind == "video" && at.IsVideo():
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filteredFeed is a Feed with only some of its activities.
+type filteredFeed struct {
+	Feed
+	id    string
+	items []Activity
+}
+
+func (f *filteredFeed) Id() string {
+	return f.id
+}
+
+func (f *filteredFeed) Items() []Activity {
+	return f.items
+}
+
+func (f *filteredFeed) Network() string {
+	return feedNetwork(f.Feed)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func optionsFor(t *testing.T, query string) (FeedOptions, error) {
+	r, err := http.NewRequest("GET", "/u/116810148281701144465?"+query, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %s", err)
+	}
+	return feedOptions(r, FeedOptions{})
+}
+
+func TestFeedFilters(t *testing.T) {
+	feed, err := fixtureRetriever(t).Find("116810148281701144465")
+	if err != nil {
+		t.Fatalf("unable to Find id: %s", err)
+	}
+	counts := map[string]int{
+		"":                           20,
+		"verb=share":                 2,
+		"verb=post":                  18,
+		"reshares=false":             18,
+		"has=video":                  1,
+		"has=article":                8,
+		"has=photo":                  11,
+		"has=photo,video":            12,
+		"include=(?i)unix":           2,
+		"exclude=(?i)unix":           18,
+		"include=Rob+Pike":           2,
+		"include=Rob+Pike&verb=post": 1,
+		"include=Google+home+page":   1,
+	}
+	for query, count := range counts {
+		opts, err := optionsFor(t, query)
+		if err != nil {
+			t.Errorf("%s: %s", query, err)
+			continue
+		}
+		if n := len(opts.Apply(feed).Items()); n != count {
+			t.Errorf("%s: want %d items, got %d", query, count, n)
+		}
+	}
+}
+
+func TestFilteredFeedIds(t *testing.T) {
+	feed := fakeFeed("1")
+	ids := make(map[string]string)
+	for _, query := range []string{"", "verb=post", "reshares=false", "has=photo,video", "include=go", "exclude=go"} {
+		opts, err := optionsFor(t, query)
+		if err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+		id := opts.Apply(feed).Id()
+		if other, ok := ids[id]; ok {
+			t.Errorf("%q and %q have the same feed id %q", query, other, id)
+		}
+		ids[id] = query
+	}
+	if _, ok := ids["plus2rss-1"]; !ok {
+		t.Errorf("unfiltered feed's id changed")
+	}
+
+	a, _ := optionsFor(t, "has=video,photo&include=go")
+	b, _ := optionsFor(t, "include=go&has=photo,video")
+	if a.Apply(feed).Id() != b.Apply(feed).Id() {
+		t.Errorf("the same filters in a different order got different ids")
+	}
+}
+
+func TestBadFeedFilters(t *testing.T) {
+	for _, query := range []string{"include=(", "exclude=[a-", "verb=like", "has=song", "has=photo,", "reshares=maybe", "media=flash"} {
+		if _, err := optionsFor(t, query); err == nil {
+			t.Errorf("%s: want an error", query)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pageKeyFormat is how a PageLimit's Since is written in a page key.
+const pageKeyFormat = time.RFC3339Nano
+
+var errBadPageKey = errors.New("malformed page key")
+
+// NewLayeredPagedFeedStorage wraps pfs in the same layers as users' first
+// pages: a stale copy is served when pfs fails or is slow, results are
+// cached for ttl, concurrent requests for the same pages share one call, and
+// users nf remembers weren't found aren't looked for again. Each user id and
+// PageLimit is a separate entry in the cache.
+func NewLayeredPagedFeedStorage(pfs PagedFeedStorage, nf *NotFoundCachingFeedStorage, ttl, staleDeadline, staleMaxAge time.Duration, maxEntries int, lg *log.Logger) PagedFeedStorage {
+	var fs FeedStorage = &pageKeyStorage{nf.Paged(pfs)}
+	fs = NewCoalescingFeedStorage(fs)
+	fs = NewCachingFeedStorage(fs, ttl, maxEntries)
+	fs = NewStaleFeedStorage(fs, staleDeadline, staleMaxAge, maxEntries, lg)
+	return &keyedPagedStorage{fs}
+}
+
+// pageKey is the id a user id and PageLimit are found by in the FeedStorage
+// that a pageKeyStorage is wrapped in.
+func pageKey(userId string, limit PageLimit) string {
+	return userId + " " + strconv.Itoa(limit.Pages) + " " + limit.Since.UTC().Format(pageKeyFormat)
+}
+
+func parsePageKey(key string) (string, PageLimit, error) {
+	parts := strings.Split(key, " ")
+	if len(parts) != 3 {
+		return "", PageLimit{}, errBadPageKey
+	}
+	pages, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", PageLimit{}, errBadPageKey
+	}
+	since, err := time.Parse(pageKeyFormat, parts[2])
+	if err != nil {
+		return "", PageLimit{}, errBadPageKey
+	}
+	return parts[0], PageLimit{Pages: pages, Since: since}, nil
+}
+
+// pageKeyStorage is a FeedStorage whose ids are pageKeys, finding the pages
+// they name in a PagedFeedStorage.
+type pageKeyStorage struct {
+	store PagedFeedStorage
+}
+
+func (p *pageKeyStorage) Find(key string) (Feed, error) {
+	userId, limit, err := parsePageKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return p.store.FindPages(userId, limit)
+}
+
+// keyedPagedStorage is a PagedFeedStorage finding pages in a FeedStorage by
+// their pageKeys.
+type keyedPagedStorage struct {
+	store FeedStorage
+}
+
+func (k *keyedPagedStorage) FindPages(userId string, limit PageLimit) (Feed, error) {
+	return k.store.Find(pageKey(userId, limit))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// pagedCounter is a PagedFeedStorage counting its calls by pageKey in a
+// countingStorage.
+type pagedCounter struct {
+	countingStorage
+}
+
+func (p *pagedCounter) FindPages(userId string, limit PageLimit) (Feed, error) {
+	_, err := p.countingStorage.Find(pageKey(userId, limit))
+	if err != nil {
+		return nil, err
+	}
+	return fakeFeed(userId), nil
+}
+
+func TestPageKey(t *testing.T) {
+	limits := []PageLimit{
+		{Pages: 3},
+		{Pages: 1, Since: time.Date(2012, 9, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, limit := range limits {
+		userId, got, err := parsePageKey(pageKey("116810148281701144465", limit))
+		if err != nil || userId != "116810148281701144465" || got.Pages != limit.Pages || !got.Since.Equal(limit.Since) {
+			t.Errorf("%+v: got %q, %+v, %v", limit, userId, got, err)
+		}
+	}
+	if _, _, err := parsePageKey("1 x 2012"); err != errBadPageKey {
+		t.Errorf("want errBadPageKey, got %v", err)
+	}
+}
+
+func TestLayeredPagedCaching(t *testing.T) {
+	pc := &pagedCounter{}
+	nf := NewNotFoundCachingFeedStorage(&countingStorage{}, time.Hour, 10)
+	pfs := NewLayeredPagedFeedStorage(pc, nf, time.Hour, time.Second, time.Hour, 10, nullLog())
+
+	three := PageLimit{Pages: 3}
+	for i := 0; i < 3; i++ {
+		if _, err := pfs.FindPages("1", three); err != nil {
+			t.Fatalf("FindPages: %s", err)
+		}
+	}
+	if n := pc.Calls(pageKey("1", three)); n != 1 {
+		t.Errorf("want 1 upstream call for 3 pages, got %d", n)
+	}
+	pfs.FindPages("1", PageLimit{Pages: 2})
+	if n := pc.Calls(pageKey("1", PageLimit{Pages: 2})); n != 1 {
+		t.Errorf("want another PageLimit to be found, got %d calls", n)
+	}
+}
+
+func TestLayeredPagedNotFound(t *testing.T) {
+	notFound := &googleapi.Error{Code: http.StatusNotFound}
+	cs := &countingStorage{err: notFound}
+	nf := NewNotFoundCachingFeedStorage(cs, time.Hour, 10)
+	pc := &pagedCounter{countingStorage{err: notFound}}
+	pfs := NewLayeredPagedFeedStorage(pc, nf, time.Hour, time.Second, time.Hour, 10, nullLog())
+
+	// A user not found on their first page isn't looked for in pages.
+	nf.Find("404")
+	if _, err := pfs.FindPages("404", PageLimit{Pages: 2}); !isNotFound(err) {
+		t.Errorf("want not found, got %v", err)
+	}
+	if n := pc.Calls(pageKey("404", PageLimit{Pages: 2})); n != 0 {
+		t.Errorf("want no upstream paged calls, got %d", n)
+	}
+
+	// And the other way around.
+	if _, err := pfs.FindPages("405", PageLimit{Pages: 2}); !isNotFound(err) {
+		t.Errorf("want not found, got %v", err)
+	}
+	nf.Find("405")
+	if n := cs.Calls("405"); n != 0 {
+		t.Errorf("want no upstream calls for the first page, got %d", n)
+	}
+}
+
+func TestLayeredPagedStale(t *testing.T) {
+	pc := &pagedCounter{}
+	nf := NewNotFoundCachingFeedStorage(&countingStorage{}, time.Hour, 10)
+	// With no caching, every FindPages goes upstream.
+	pfs := NewLayeredPagedFeedStorage(pc, nf, 0, time.Second, time.Hour, 10, nullLog())
+
+	limit := PageLimit{Pages: 2}
+	if _, err := pfs.FindPages("1", limit); err != nil {
+		t.Fatalf("FindPages: %s", err)
+	}
+	pc.mu.Lock()
+	pc.err = errors.New("boom")
+	pc.mu.Unlock()
+	feed, err := pfs.FindPages("1", limit)
+	if err != nil {
+		t.Fatalf("FindPages with failing upstream: %s", err)
+	}
+	if _, ok := feed.(*StaleFeed); !ok {
+		t.Errorf("want a *StaleFeed, got %T", feed)
+	}
+}
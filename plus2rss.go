@@ -2,7 +2,7 @@ package main
 
 import (
 	"flag"
-	"io/ioutil"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -24,35 +24,134 @@ const (
 var (
 	frontendHost         = flag.String("vhost", "localhost:6543", "the virtual Host header to respond to in the frontend")
 	frontendAddr         = flag.String("http", "localhost:6543", "address to run the frontend on (e.g. :6543, localhost:4321)")
-	simpleKeyFile        = flag.String("simpleKeyFile", "", "file containing a working Google simple key")
+	simpleKeyFile        = flag.String("simpleKeyFile", "", "file containing working Google simple keys, one to a line, taken in turns")
+	keyCooldown          = flag.Duration("keyCooldown", 15*time.Minute, "how long a simple key that has run out of quota is left out of the turns")
+	source               = flag.String("source", "api", "where posts come from: the Google+ \"api\", a \"takeout\" export in -takeoutDir, or the \"fixtures\" in -fixtureDir")
+	takeoutDir           = flag.String("takeoutDir", "", "directory of a Google Takeout export of Google+ Stream, for -source=takeout")
+	fixtureDir           = flag.String("fixtureDir", "", "directory of recorded or hand-written Google+ API responses, for -source=fixtures")
+	fixtureReload        = flag.Duration("fixtureReload", 10*time.Second, "how often to check -fixtureDir for changes (0 disables reloading)")
+	mastodonInstances    = flag.String("mastodonInstances", "", "comma-separated Mastodon instances (e.g. mastodon.social) whose accounts have feeds at /mastodon/USER@INSTANCE")
 	templateDir          = flag.String("templateDir", "./templates", "Directory containing the templates to render html and feeds")
 	frontendReadTimeout  = flag.Duration("frontendReadTimeout", timeout, "frontend http server's total request read timeout")
 	frontendWriteTimeout = flag.Duration("frontendWriteTimeout", timeout, "frontend http server's total request write timeout")
 	controlAddr          = flag.String("controlAddr", "localhost:5432", "the address to run the control HTTP server on")
+	cacheTTL             = flag.Duration("cacheTTL", 5*time.Minute, "how long a found feed is served from memory before asking Google+ again (raised to twice -refreshMaxInterval while background refreshes are on)")
+	cacheMaxEntries      = flag.Int("cacheMaxEntries", 1000, "maximum number of feeds kept in memory")
+	notFoundTTL          = flag.Duration("notFoundTTL", time.Hour, "how long a user Google+ says doesn't exist is remembered as missing")
+	staleDeadline        = flag.Duration("staleDeadline", 2*time.Second, "how long to wait on Google+ before serving the last good copy of a feed")
+	staleMaxAge          = flag.Duration("staleMaxAge", 24*time.Hour, "how long the last good copy of a feed may be served while Google+ is failing")
+	archiveDir           = flag.String("archiveDir", "", "directory to keep every post seen for each user in (disabled if empty)")
+	archiveEntries       = flag.Int("archiveEntries", 100, "number of archived posts to put in each feed")
+	refreshBudget        = flag.Int("refreshBudget", 30, "most background feed refreshes to make per minute (0 disables background refreshes)")
+	refreshMinInterval   = flag.Duration("refreshMinInterval", 5*time.Minute, "shortest time between background refreshes of a frequent poster's feed")
+	refreshMaxInterval   = flag.Duration("refreshMaxInterval", 2*time.Hour, "longest time between background refreshes of a quiet feed")
+	refreshIdle          = flag.Duration("refreshIdle", 72*time.Hour, "how long after its last request a feed stops being refreshed in the background")
+	maxPages             = flag.Int("maxPages", 10, "most pages of posts a ?pages= or ?since= feed request may walk back through")
+	backfill             = flag.String("backfill", "", "comma-separated user ids whose history to copy into -archiveDir before exiting")
+	backfillPages        = flag.Int("backfillPages", 100, "most pages of posts -backfill walks back through per user")
+	backfillSince        = flag.String("backfillSince", "", "date (e.g. 2012-09-01) before which -backfill stops looking")
+	titlePolicy          = flag.String("titles", TitleDerived, "how entry titles are made: \"derived\" from the post's first sentence, or the \"api\" title from Google+ (feeds may override with ?title=)")
+	titleLength          = flag.Int("titleLength", 80, "most characters in a derived entry title")
+	listsFile            = flag.String("listsFile", "", "JSON file of lists of users to serve merged feeds of at /m/LIST_ID")
+	mergeParallelism     = flag.Int("mergeParallelism", 8, "most users' feeds a merged feed request finds at once")
+	mergeMaxUsers        = flag.Int("mergeMaxUsers", 50, "most users a merged feed may have")
+	mergeEntries         = flag.Int("mergeEntries", 100, "number of posts to put in a merged feed")
+	searchTTL            = flag.Duration("searchTTL", 10*time.Minute, "how long the results of a search are served from memory")
+	searchQueryInterval  = flag.Duration("searchQueryInterval", 5*time.Minute, "shortest time between sending the same search to Google+")
+	searchPerMinute      = flag.Int("searchPerMinute", 10, "most searches to send to Google+ per minute")
 	registry             = metrics.NewRegistry()
 	bootTime             = time.Now().UTC()
 )
 
-// TODO: handle posts that were reshares
 func main() {
 	flag.Parse()
 	lg := log.New(os.Stderr, "", 0)
-	if *simpleKeyFile == "" {
-		lg.Fatalf("plus2rss: -simpleKeyFile=FILE is a required command-line argument")
+	switch *source {
+	case "api":
+		if *simpleKeyFile == "" {
+			lg.Fatalf("plus2rss: -simpleKeyFile=FILE is a required command-line argument")
+		}
+	case "takeout":
+		if *takeoutDir == "" {
+			lg.Fatalf("plus2rss: -source=takeout requires -takeoutDir=DIR")
+		}
+	case "fixtures":
+		if *fixtureDir == "" {
+			lg.Fatalf("plus2rss: -source=fixtures requires -fixtureDir=DIR")
+		}
+	default:
+		lg.Fatalf("plus2rss: -source must be \"api\", \"takeout\" or \"fixtures\"")
+	}
+	if !validTitlePolicy(*titlePolicy) {
+		lg.Fatalf("plus2rss: -titles must be %q or %q", TitleDerived, TitleFromAPI)
+	}
+	if *mergeParallelism < 1 {
+		lg.Fatalf("plus2rss: -mergeParallelism must be at least 1")
+	}
+	if *searchTTL < *searchQueryInterval {
+		lg.Fatalf("plus2rss: -searchTTL must be at least -searchQueryInterval, or searches would be rate limited while they aren't cached")
+	}
+	var lists map[string]*MergedList
+	if *listsFile != "" {
+		var err error
+		lists, err = LoadLists(*listsFile, *mergeMaxUsers)
+		if err != nil {
+			lg.Fatalf("Could not load -listsFile: %s", err)
+		}
+	}
+
+	ttl := *cacheTTL
+	if *refreshBudget > 0 && ttl < *refreshMaxInterval*2 {
+		// The scheduler keeps cached feeds fresh, so they only need to
+		// expire once it has stopped refreshing them.
+		ttl = *refreshMaxInterval * 2
+		lg.Printf("plus2rss: raising -cacheTTL from %s to %s, twice -refreshMaxInterval, since feeds are refreshed in the background", *cacheTTL, ttl)
+	}
+	be, media, err := backend(*source, *simpleKeyFile, *keyCooldown, *takeoutDir, *fixtureDir, *fixtureReload, *frontendHost, lg)
+	if err != nil {
+		lg.Fatalf("Could not boot the %s backend: %s", *source, err)
+	}
+	fs, err := feedStorage(be, *archiveDir, *archiveEntries, ttl, *notFoundTTL, *cacheMaxEntries, lg)
+	if err != nil {
+		lg.Fatalf("Could not boot feed storage: %s", err)
+	}
+	if *backfill != "" {
+		if *archiveDir == "" {
+			lg.Fatalf("plus2rss: -backfill requires -archiveDir")
+		}
+		err = backfillArchive(fs.paged, *backfill, *backfillPages, *backfillSince, lg)
+		if err != nil {
+			lg.Fatalf("Backfill failed: %s", err)
+		}
+		return
+	}
+	ss := NewStaleFeedStorage(fs.cache, *staleDeadline, *staleMaxAge, *cacheMaxEntries, lg)
+	registerStaleGauges(ss)
+	var readerStore FeedStorage = ss
+	if *refreshBudget > 0 {
+		rs := NewRefreshScheduler(ss, fs.cache, *refreshMinInterval, *refreshMaxInterval, *refreshIdle, *refreshBudget, lg)
+		registerSchedulerGauges(rs)
+		go rs.Run()
+		readerStore = rs
 	}
 
 	ch := make(chan error)
-	cs := NewStatServer(*controlAddr)
+	cs := NewStatServer(*controlAddr, ss, fs.notFound)
 	go func() {
 		ch <- cs.ListenAndServe()
 	}()
 
-	fs, err := feedStorage(*simpleKeyFile, lg)
-	if err != nil {
-		lg.Fatalf("Could not boot feed storage: %s", err)
+	sources := NewPlusSources(readerStore)
+	if *mastodonInstances != "" {
+		ms := NewMastodonStorage(&http.Client{Timeout: timeout}, strings.Split(*mastodonInstances, ","), 20, lg)
+		sources.Register(ms.SourceType(NewCachingFeedStorage(ms, *cacheTTL, *cacheMaxEntries)))
 	}
-
-	fr := frontend(fs, *frontendHost, *frontendAddr, *templateDir, *frontendReadTimeout, *frontendWriteTimeout)
+	mg := NewMerger(readerStore, lists, *mergeParallelism, *mergeMaxUsers, *mergeEntries, lg)
+	defaults := FeedOptions{TitlePolicy: *titlePolicy, TitleLength: *titleLength}
+	search := NewCachingSearchStorage(fs.backend, *searchTTL, *searchQueryInterval, *searchPerMinute, *cacheMaxEntries)
+	comments := NewCachingCommentStorage(fs.backend, *cacheTTL, *cacheMaxEntries)
+	pagedStore := NewLayeredPagedFeedStorage(fs.paged, fs.notFound, *cacheTTL, *staleDeadline, *staleMaxAge, *cacheMaxEntries, lg)
+	fr := frontend(readerStore, pagedStore, sources, search, comments, mg, *maxPages, defaults, media, *frontendHost, *frontendAddr, *templateDir, *frontendReadTimeout, *frontendWriteTimeout)
 	go func() {
 		ch <- fr.ListenAndServe()
 	}()
@@ -61,22 +160,92 @@ func main() {
 	lg.Printf("frontend shutdown: %s", err)
 }
 
-func feedStorage(simpleFile string, lg *log.Logger) (FeedStorage, error) {
-	simpleKey, err := ioutil.ReadFile(simpleFile)
+// backend makes the Backend for source. A Takeout export also has media
+// files to serve, which media does.
+func backend(source, simpleFile string, keyCooldown time.Duration, takeoutDir, fixtureDir string, fixtureReload time.Duration, host string, lg *log.Logger) (be Backend, media http.Handler, err error) {
+	switch source {
+	case "takeout":
+		ts, err := LoadTakeout(takeoutDir, "http://"+host+"/takeout/", lg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ts, ts, nil
+	case "fixtures":
+		fs, err := LoadFixtures(fixtureDir, lg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if fixtureReload > 0 {
+			go fs.Watch(fixtureReload)
+		}
+		return fs, nil, nil
+	}
+	keys, err := ReadKeys(simpleFile)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	key := strings.TrimSpace(string(simpleKey))
-	t := &SimpleKeyTransport{Key: key, Transport: http.DefaultTransport}
+	t := NewKeyPoolTransport(keys, keyCooldown, http.DefaultTransport, registry)
 	srv, err := plus.New(&http.Client{Transport: t})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	retriever := &FeedRetriever{srv, lg}
-	return retriever, nil
+	return &FeedRetriever{srv, lg}, nil, nil
 }
 
-func frontend(fs FeedStorage, host, addr, templateDir string, readTimeout, writeTimeout time.Duration) *http.Server {
-	m := NewFrontendMux(fs, host, templateDir)
+// feedStores holds the layers of FeedStorage in front of the Backend that
+// need to be reached individually.
+type feedStores struct {
+	cache    *CachingFeedStorage
+	notFound *NotFoundCachingFeedStorage
+	paged    PagedFeedStorage
+	backend  Backend
+}
+
+func feedStorage(be Backend, archiveDir string, archiveEntries int, ttl, notFoundTTL time.Duration, maxEntries int, lg *log.Logger) (*feedStores, error) {
+	var fs FeedStorage = be
+	var pfs PagedFeedStorage = be
+	if archiveDir != "" {
+		as, err := NewArchiveFeedStorage(be, be, archiveDir, archiveEntries, lg)
+		if err != nil {
+			return nil, err
+		}
+		fs, pfs = as, as
+	}
+	coalescer := NewCoalescingFeedStorage(fs)
+	notFound := NewNotFoundCachingFeedStorage(coalescer, notFoundTTL, maxEntries)
+	cache := NewCachingFeedStorage(notFound, ttl, maxEntries)
+	return &feedStores{cache, notFound, pfs, be}, nil
+}
+
+// backfillArchive walks back through the history of each of the
+// comma-separated userIds so that it ends up in the archive.
+func backfillArchive(pfs PagedFeedStorage, userIds string, pages int, since string, lg *log.Logger) error {
+	limit := PageLimit{Pages: pages}
+	if since != "" {
+		var err error
+		limit.Since, err = ParseSince(since)
+		if err != nil {
+			return err
+		}
+	}
+	for _, userId := range strings.Split(userIds, ",") {
+		userId = strings.TrimSpace(userId)
+		feed, err := pfs.FindPages(userId, limit)
+		if err != nil {
+			return fmt.Errorf("%s: %s", userId, err)
+		}
+		lg.Printf("Backfilled %d posts of %s", len(feed.Items()), userId)
+	}
+	return nil
+}
+
+func frontend(fs FeedStorage, pfs PagedFeedStorage, sources *SourceRegistry, search SearchStorage, comments CommentStorage, mg *Merger, maxPages int, defaults FeedOptions, media http.Handler, host, addr, templateDir string, readTimeout, writeTimeout time.Duration) *http.Server {
+	m := NewFrontendMux(fs, pfs, sources, search, comments, mg, maxPages, defaults, host, templateDir)
+	if media != nil {
+		mux := http.NewServeMux()
+		mux.Handle("/takeout/", http.StripPrefix("/takeout/", media))
+		mux.Handle("/", m)
+		m = mux
+	}
 	return &http.Server{Addr: addr, Handler: m, ReadTimeout: readTimeout, WriteTimeout: writeTimeout}
 }
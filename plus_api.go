@@ -1,23 +1,9 @@
 package main
 
 import (
-	"net/http"
-
 	plus "google.golang.org/api/plus/v1"
 )
 
-type SimpleKeyTransport struct {
-	Key       string
-	Transport http.RoundTripper
-}
-
-func (t *SimpleKeyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
-	q := r.URL.Query()
-	q.Set("key", t.Key)
-	r.URL.RawQuery = q.Encode()
-	return t.Transport.RoundTrip(r)
-}
-
 // ActorFeed implements the Feed iterface
 type ActorFeed struct {
 	actor *plus.Person
@@ -33,9 +19,33 @@ type Activity interface {
 	Id() string
 	URL() string
 	ActorName() string
+	ActorURL() string
 	Attachments() []Attachment
-}
 
+	// ContentHTML is Content made safe to embed in a feed, and ContentText
+	// is its text without any markup.
+	ContentHTML() string
+	ContentText() string
+
+	// For reshares, Content is the original post's and Annotation is what
+	// the resharer added to it.
+	IsReshare() bool
+	Annotation() string
+	OriginalActorName() string
+	OriginalActorURL() string
+	OriginalURL() string
+
+	// Replies is how many comments there are on the activity. InReplyTo is
+	// the URL of the activity a comment is on, and empty for activities.
+	// CommentsId is the id the feed of its comments is found by, or empty
+	// if there isn't one.
+	Replies() int64
+	InReplyTo() string
+	CommentsId() string
+}
+
+// Attachment is something attached to an activity. Image, FullImage and
+// Embed are nil when Google+ didn't send them, which it often doesn't.
 type Attachment interface {
 	ObjectType() string
 	DisplayName() string
@@ -44,9 +54,14 @@ type Attachment interface {
 	URL() string
 	Image() Image
 	FullImage() Image
+	Embed() Embed
+	Thumbnails() []Thumbnail
 	IsVideo() bool
 	IsPhoto() bool
 	IsArticle() bool
+	IsAlbum() bool
+	IsEvent() bool
+	IsAudio() bool
 }
 
 type Image interface {
@@ -61,10 +76,26 @@ type Embed interface {
 	Type() string
 }
 
+// Thumbnail is a preview of one of the things in an album. Its Image is nil
+// if Google+ didn't send one.
+type Thumbnail interface {
+	URL() string
+	Description() string
+	Image() Image
+}
+
 type JSONImage struct {
 	pI plus.ActivityObjectAttachmentsImage
 }
 
+type JSONEmbed struct {
+	pE plus.ActivityObjectAttachmentsEmbed
+}
+
+type JSONThumbnail struct {
+	pT plus.ActivityObjectAttachmentsThumbnails
+}
+
 type JSONAttachment struct {
 	pAO plus.ActivityObjectAttachments
 }
@@ -117,6 +148,14 @@ func (a *JSONActivity) Content() string {
 	return a.pA.Object.Content
 }
 
+func (a *JSONActivity) ContentHTML() string {
+	return sanitizeHTML(a.pA.Object.Content)
+}
+
+func (a *JSONActivity) ContentText() string {
+	return htmlText(a.pA.Object.Content)
+}
+
 func (a *JSONActivity) Title() string {
 	return a.pA.Title
 }
@@ -133,6 +172,51 @@ func (a *JSONActivity) ActorName() string {
 	return a.pA.Actor.DisplayName
 }
 
+func (a *JSONActivity) ActorURL() string {
+	return a.pA.Actor.Url
+}
+
+func (a *JSONActivity) IsReshare() bool {
+	return a.pA.Verb == "share"
+}
+
+func (a *JSONActivity) Annotation() string {
+	return a.pA.Annotation
+}
+
+func (a *JSONActivity) OriginalActorName() string {
+	if a.pA.Object.Actor == nil {
+		return ""
+	}
+	return a.pA.Object.Actor.DisplayName
+}
+
+func (a *JSONActivity) OriginalActorURL() string {
+	if a.pA.Object.Actor == nil {
+		return ""
+	}
+	return a.pA.Object.Actor.Url
+}
+
+func (a *JSONActivity) OriginalURL() string {
+	return a.pA.Object.Url
+}
+
+func (a *JSONActivity) Replies() int64 {
+	if a.pA.Object.Replies == nil {
+		return 0
+	}
+	return a.pA.Object.Replies.TotalItems
+}
+
+func (a *JSONActivity) InReplyTo() string {
+	return ""
+}
+
+func (a *JSONActivity) CommentsId() string {
+	return a.pA.Id
+}
+
 func (a *JSONActivity) Attachments() []Attachment {
 	as := make([]Attachment, len(a.pA.Object.Attachments))
 	for i, ao := range a.pA.Object.Attachments {
@@ -157,12 +241,37 @@ func (a *JSONAttachment) URL() string {
 	return a.pAO.Url
 }
 func (a *JSONAttachment) Image() Image {
+	if a.pAO.Image == nil {
+		return nil
+	}
 	return &JSONImage{*a.pAO.Image}
 }
 func (a *JSONAttachment) FullImage() Image {
 	fi := a.pAO.FullImage
-	ai := plus.ActivityObjectAttachmentsImage{fi.Height, fi.Type, fi.Url, fi.Width, nil}
-	return &JSONImage{ai}
+	if fi == nil {
+		return nil
+	}
+	return &JSONImage{plus.ActivityObjectAttachmentsImage{
+		Height: fi.Height,
+		Type:   fi.Type,
+		Url:    fi.Url,
+		Width:  fi.Width,
+	}}
+}
+func (a *JSONAttachment) Embed() Embed {
+	if a.pAO.Embed == nil {
+		return nil
+	}
+	return &JSONEmbed{*a.pAO.Embed}
+}
+func (a *JSONAttachment) Thumbnails() []Thumbnail {
+	var ts []Thumbnail
+	for _, t := range a.pAO.Thumbnails {
+		if t != nil {
+			ts = append(ts, &JSONThumbnail{*t})
+		}
+	}
+	return ts
 }
 
 func (a *JSONAttachment) IsVideo() bool {
@@ -175,6 +284,18 @@ func (a *JSONAttachment) IsArticle() bool {
 	return a.pAO.ObjectType == "article"
 }
 
+// IsAlbum is true for both kinds of album Google+ sends, "album" and
+// "photo-album".
+func (a *JSONAttachment) IsAlbum() bool {
+	return a.pAO.ObjectType == "album" || a.pAO.ObjectType == "photo-album"
+}
+func (a *JSONAttachment) IsEvent() bool {
+	return a.pAO.ObjectType == "event"
+}
+func (a *JSONAttachment) IsAudio() bool {
+	return a.pAO.ObjectType == "audio"
+}
+
 func (i *JSONImage) URL() string {
 	return i.pI.Url
 }
@@ -190,3 +311,32 @@ func (i *JSONImage) Height() int64 {
 func (i *JSONImage) Width() int64 {
 	return i.pI.Width
 }
+
+func (e *JSONEmbed) URL() string {
+	return e.pE.Url
+}
+
+func (e *JSONEmbed) Type() string {
+	return e.pE.Type
+}
+
+func (t *JSONThumbnail) URL() string {
+	return t.pT.Url
+}
+
+func (t *JSONThumbnail) Description() string {
+	return t.pT.Description
+}
+
+func (t *JSONThumbnail) Image() Image {
+	ti := t.pT.Image
+	if ti == nil {
+		return nil
+	}
+	return &JSONImage{plus.ActivityObjectAttachmentsImage{
+		Height: ti.Height,
+		Type:   ti.Type,
+		Url:    ti.Url,
+		Width:  ti.Width,
+	}}
+}
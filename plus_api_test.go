@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	plus "google.golang.org/api/plus/v1"
+)
+
+// attachmentsFeed is the feed in testdata/attachments.json, which has an
+// attachment of each type the Google+ feed fixture lacks.
+func attachmentsFeed(t *testing.T) *ActorFeed {
+	b, err := ioutil.ReadFile("testdata/attachments.json")
+	if err != nil {
+		t.Fatalf("unable to read fixture: %s", err)
+	}
+	pf := &plus.ActivityFeed{}
+	if err := json.Unmarshal(b, pf); err != nil {
+		t.Fatalf("unable to parse fixture: %s", err)
+	}
+	return &ActorFeed{&plus.Person{Id: "100000000000000000001", DisplayName: "Test User"}, pf}
+}
+
+func TestFixtureAttachments(t *testing.T) {
+	feed, err := fixtureRetriever(t).Find("116810148281701144465")
+	if err != nil {
+		t.Fatalf("unable to Find id: %s", err)
+	}
+	items := feed.Items()
+
+	video := items[6].Attachments()[0]
+	if !video.IsVideo() || video.Image() == nil || video.FullImage() != nil {
+		t.Errorf("video: IsVideo %t, Image %v, FullImage %v", video.IsVideo(), video.Image(), video.FullImage())
+	}
+	if e := video.Embed(); e == nil || e.Type() != "application/x-shockwave-flash" || !strings.HasPrefix(e.URL(), "http://www.youtube.com/v/") {
+		t.Errorf("video embed: got %v", e)
+	}
+
+	photo := items[15].Attachments()[0]
+	if !photo.IsPhoto() || photo.Image() == nil || photo.FullImage() == nil {
+		t.Fatalf("photo: IsPhoto %t, Image %v, FullImage %v", photo.IsPhoto(), photo.Image(), photo.FullImage())
+	}
+	fi := photo.FullImage()
+	if fi.URL() != "https://lh3.googleusercontent.com/-tf_OJXcdkdI/T5VooEvxTrI/AAAAAAAAAmQ/iqtpYtL86E8/photo.jpg" || fi.Type() != "image/png" || fi.Width() != 1161 || fi.Height() != 509 {
+		t.Errorf("photo full image: got %q %q %dx%d", fi.URL(), fi.Type(), fi.Width(), fi.Height())
+	}
+	if photo.Embed() != nil {
+		t.Errorf("photo has an embed: %v", photo.Embed())
+	}
+
+	album := items[15].Attachments()[1]
+	if !album.IsAlbum() || album.Image() != nil || album.FullImage() != nil || len(album.Thumbnails()) != 0 {
+		t.Errorf("photo-album: IsAlbum %t, Image %v, FullImage %v, Thumbnails %v", album.IsAlbum(), album.Image(), album.FullImage(), album.Thumbnails())
+	}
+}
+
+func TestAttachmentTypes(t *testing.T) {
+	items := attachmentsFeed(t).Items()
+
+	album := items[0].Attachments()[0]
+	if !album.IsAlbum() || album.IsPhoto() {
+		t.Errorf("album: IsAlbum %t, IsPhoto %t", album.IsAlbum(), album.IsPhoto())
+	}
+	ts := album.Thumbnails()
+	if len(ts) != 2 {
+		t.Fatalf("want 2 album thumbnails, got %d", len(ts))
+	}
+	if ts[0].Description() != "The beach" || ts[0].Image() == nil || ts[0].Image().Width() != 150 {
+		t.Errorf("first thumbnail: %q %v", ts[0].Description(), ts[0].Image())
+	}
+	if ts[1].Image() != nil {
+		t.Errorf("second thumbnail has an image: %v", ts[1].Image())
+	}
+
+	event := items[1].Attachments()[0]
+	if !event.IsEvent() || event.Content() != "Saturday at eight" {
+		t.Errorf("event: IsEvent %t, Content %q", event.IsEvent(), event.Content())
+	}
+
+	audio := items[2].Attachments()[0]
+	if !audio.IsAudio() || audio.Embed() == nil || audio.Embed().Type() != "audio/mpeg" {
+		t.Errorf("audio: IsAudio %t, Embed %v", audio.IsAudio(), audio.Embed())
+	}
+
+	photo := items[3].Attachments()[0]
+	if !photo.IsPhoto() || photo.Image() != nil || photo.FullImage() != nil || photo.Embed() != nil {
+		t.Errorf("imageless photo: IsPhoto %t, Image %v, FullImage %v, Embed %v", photo.IsPhoto(), photo.Image(), photo.FullImage(), photo.Embed())
+	}
+	if !items[3].Attachments()[1].IsAlbum() {
+		t.Errorf("photo-album isn't an album")
+	}
+}
+
+func TestRenderAttachmentTypes(t *testing.T) {
+	fv := testFeedView(attachmentsFeed(t))
+	for _, iv := range fv.ItemViews() {
+		if _, err := iv.Description(); err != nil {
+			t.Errorf("%s: %s", iv.Id(), err)
+		}
+		if e := iv.Enclosure(); e != nil {
+			t.Errorf("%s: unexpected enclosure %+v", iv.Id(), e)
+		}
+	}
+	doc := renderAtom(t, fv)
+	if len(doc.Entries) != 4 {
+		t.Fatalf("want 4 entries, got %d", len(doc.Entries))
+	}
+	if !strings.Contains(doc.Entries[0].Content.Body, "https://lh3.googleusercontent.com/beach.jpg") {
+		t.Errorf("album thumbnail missing from content: %s", doc.Entries[0].Content.Body)
+	}
+	if !strings.Contains(doc.Entries[1].Content.Body, "Saturday at eight") {
+		t.Errorf("event details missing from content: %s", doc.Entries[1].Content.Body)
+	}
+	for _, l := range doc.Entries[3].Links {
+		if l.Rel == "enclosure" {
+			t.Errorf("imageless photo got an enclosure: %+v", l)
+		}
+	}
+}
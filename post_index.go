@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+	plus "google.golang.org/api/plus/v1"
+)
+
+// indexPageSize is how many posts a page of a user's feed has when it comes
+// from a postIndex, the same as a page from the Google+ API.
+const indexPageSize = 20
+
+// postIndex holds posts, and the people and comments that go with them, in
+// memory, and finds feeds in them the way a FeedRetriever finds them in
+// Google+. It's for backends that have every post at hand. Once finish has
+// been called, it must not be changed, and is then safe for concurrent use.
+type postIndex struct {
+	what       string // what the posts are from, for not found errors
+	people     map[string]*plus.Person
+	activities map[string][]*plus.Activity // by user id, newest first
+	byId       map[string]*plus.Activity
+	comments   map[string][]*plus.Comment // by activity id, newest first
+	all        []*plus.Activity
+}
+
+func newPostIndex(what string) *postIndex {
+	return &postIndex{
+		what:       what,
+		people:     make(map[string]*plus.Person),
+		activities: make(map[string][]*plus.Activity),
+		byId:       make(map[string]*plus.Activity),
+		comments:   make(map[string][]*plus.Comment),
+	}
+}
+
+// addPerson adds p, who may also be found by any of aliases, like their
+// +Name.
+func (x *postIndex) addPerson(p *plus.Person, aliases ...string) {
+	x.people[p.Id] = p
+	for _, alias := range aliases {
+		x.people[alias] = p
+	}
+}
+
+// addActivity adds a, unless an activity with its id is already there, or
+// it's missing its actor or object.
+func (x *postIndex) addActivity(a *plus.Activity) {
+	if _, ok := x.byId[a.Id]; ok || a.Actor == nil || a.Object == nil {
+		return
+	}
+	x.activities[a.Actor.Id] = append(x.activities[a.Actor.Id], a)
+	x.byId[a.Id] = a
+	x.all = append(x.all, a)
+}
+
+func (x *postIndex) addComments(activityId string, cs ...*plus.Comment) {
+	x.comments[activityId] = append(x.comments[activityId], cs...)
+}
+
+// finish sorts everything newest first, and makes up the people whose posts
+// were added without them from the posts' actors.
+func (x *postIndex) finish() {
+	for userId, acts := range x.activities {
+		sort.Sort(byPublished(acts))
+		if _, ok := x.people[userId]; !ok {
+			actor := acts[0].Actor
+			x.people[userId] = &plus.Person{Id: actor.Id, DisplayName: actor.DisplayName, Url: actor.Url}
+		}
+	}
+	sort.Sort(byPublished(x.all))
+	for _, cs := range x.comments {
+		sort.Slice(cs, func(i, j int) bool {
+			return parseTime(cs[i].Published).After(parseTime(cs[j].Published))
+		})
+	}
+}
+
+func (x *postIndex) notFound(what string) error {
+	return &googleapi.Error{Code: http.StatusNotFound, Message: what + " isn't in the " + x.what}
+}
+
+// Find is the user's first page of posts.
+func (x *postIndex) Find(userId string) (Feed, error) {
+	return x.FindPages(userId, PageLimit{Pages: 1})
+}
+
+func (x *postIndex) FindPages(userId string, limit PageLimit) (Feed, error) {
+	person, ok := x.people[userId]
+	if !ok {
+		return nil, x.notFound("user " + userId)
+	}
+	all := x.activities[person.Id]
+	n := indexPageSize
+	if limit.Pages > 1 {
+		n *= limit.Pages
+	}
+	if len(all) < n {
+		n = len(all)
+	}
+	items := append([]*plus.Activity(nil), all[:n]...)
+	if !limit.Since.IsZero() {
+		items, _ = itemsSince(items, limit.Since, true)
+	}
+	return &ActorFeed{person, activityFeed("Plus Public Activity Feed for "+person.DisplayName, items)}, nil
+}
+
+// Search finds the newest posts whose text contains the query, ignoring
+// case. Resharers' annotations are searched, too.
+func (x *postIndex) Search(query string) (Feed, error) {
+	q := strings.ToLower(query)
+	var items []*plus.Activity
+	for _, a := range x.all {
+		if len(items) == indexPageSize {
+			break
+		}
+		text := htmlText(a.Object.Content) + "\n" + htmlText(a.Annotation)
+		if strings.Contains(strings.ToLower(text), q) {
+			items = append(items, a)
+		}
+	}
+	return &SearchFeed{query, activityFeed("", items)}, nil
+}
+
+func (x *postIndex) FindComments(activityId string) (Feed, error) {
+	a, ok := x.byId[activityId]
+	if !ok {
+		return nil, x.notFound("activity " + activityId)
+	}
+	cf := &plus.CommentFeed{Items: x.comments[activityId]}
+	if len(cf.Items) > 0 {
+		cf.Updated = cf.Items[0].Updated
+	}
+	return &CommentsFeed{a, cf}, nil
+}
+
+// activityFeed is a page of items, updated when the latest of them was.
+func activityFeed(title string, items []*plus.Activity) *plus.ActivityFeed {
+	feed := &plus.ActivityFeed{Title: title, Items: items}
+	for _, a := range items {
+		if feed.Updated == "" || parseTime(a.Updated).After(parseTime(feed.Updated)) {
+			feed.Updated = a.Updated
+		}
+	}
+	return feed
+}
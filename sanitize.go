@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// plusBase is what relative links in Google+ posts are relative to.
+var plusBase = &url.URL{Scheme: "https", Host: "plus.google.com", Path: "/"}
+
+// allowedTags maps the tags that survive sanitizeHTML to the attributes
+// they may keep.
+var allowedTags = map[string][]string{
+	"a":          {"href", "title"},
+	"b":          nil,
+	"blockquote": nil,
+	"br":         nil,
+	"code":       nil,
+	"del":        nil,
+	"em":         nil,
+	"i":          nil,
+	"img":        {"src", "alt", "title", "width", "height"},
+	"li":         nil,
+	"ol":         nil,
+	"p":          nil,
+	"pre":        nil,
+	"s":          nil,
+	"span":       nil,
+	"strong":     nil,
+	"u":          nil,
+	"ul":         nil,
+}
+
+// droppedTags are left out along with everything inside them.
+var droppedTags = map[string]bool{
+	"applet":   true,
+	"head":     true,
+	"iframe":   true,
+	"noscript": true,
+	"object":   true,
+	"script":   true,
+	"style":    true,
+	"template": true,
+	"title":    true,
+}
+
+// urlAttrs are the attributes whose values are links.
+var urlAttrs = map[string]bool{"href": true, "src": true}
+
+// sanitizeHTML rewrites the HTML in a post so it's safe to put in a feed.
+// Only the allowedTags and their attributes are kept, the droppedTags go
+// with their contents, links are made absolute against plus.google.com
+// and any that aren't http, https or mailto are removed, and images too
+// small to see, which are there to track readers, are left out.
+func sanitizeHTML(s string) string {
+	var buf bytes.Buffer
+	z := html.NewTokenizer(strings.NewReader(s))
+	var dropping, open []string
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		t := z.Token()
+		if len(dropping) > 0 {
+			switch {
+			case tt == html.StartTagToken && droppedTags[t.Data]:
+				dropping = append(dropping, t.Data)
+			case tt == html.EndTagToken && t.Data == dropping[len(dropping)-1]:
+				dropping = dropping[:len(dropping)-1]
+			}
+			continue
+		}
+
+		switch tt {
+		case html.TextToken:
+			buf.WriteString(html.EscapeString(t.Data))
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedTags[t.Data] {
+				if tt == html.StartTagToken {
+					dropping = append(dropping, t.Data)
+				}
+				continue
+			}
+			allowed, ok := allowedTags[t.Data]
+			if !ok || (t.Data == "img" && isTrackingPixel(t)) {
+				continue
+			}
+			attrs := sanitizeAttrs(t.Attr, allowed)
+			if t.Data == "img" && !hasAttr(attrs, "src") {
+				continue
+			}
+			buf.WriteString("<" + t.Data)
+			for _, a := range attrs {
+				buf.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+			}
+			if isVoid(t.Data) {
+				buf.WriteString(" />")
+			} else {
+				buf.WriteString(">")
+				open = append(open, t.Data)
+			}
+		case html.EndTagToken:
+			// Close the tag and anything left open inside it. End tags
+			// for tags that aren't open are dropped.
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == t.Data {
+					for len(open) > i {
+						buf.WriteString("</" + open[len(open)-1] + ">")
+						open = open[:len(open)-1]
+					}
+					break
+				}
+			}
+		}
+	}
+	// Close anything the post left open, so it can't swallow what comes
+	// after it in the feed.
+	for i := len(open) - 1; i >= 0; i-- {
+		buf.WriteString("</" + open[i] + ">")
+	}
+	return buf.String()
+}
+
+// htmlText is the text of the HTML s, with line breaks where s had breaks,
+// paragraphs or list items.
+func htmlText(s string) string {
+	var buf bytes.Buffer
+	z := html.NewTokenizer(strings.NewReader(s))
+	dropping := 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		t := z.Token()
+		switch tt {
+		case html.TextToken:
+			if dropping == 0 {
+				buf.WriteString(t.Data)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedTags[t.Data] && tt == html.StartTagToken {
+				dropping++
+			}
+			switch t.Data {
+			case "br", "p", "li", "blockquote", "div":
+				if dropping == 0 && buf.Len() > 0 {
+					buf.WriteString("\n")
+				}
+			}
+		case html.EndTagToken:
+			if droppedTags[t.Data] && dropping > 0 {
+				dropping--
+			}
+		}
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// sanitizeAttrs keeps the attributes in allowed, which takes care of event
+// handlers and styles, and fixes up or removes their links.
+func sanitizeAttrs(attrs []html.Attribute, allowed []string) []html.Attribute {
+	var out []html.Attribute
+	for _, a := range attrs {
+		if a.Namespace != "" || !contains(allowed, a.Key) {
+			continue
+		}
+		if urlAttrs[a.Key] {
+			abs, ok := absoluteURL(a.Val)
+			if !ok {
+				continue
+			}
+			a.Val = abs
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// absoluteURL resolves rawurl against plusBase, and reports false if it
+// doesn't parse or isn't a kind of link that's safe to follow.
+func absoluteURL(rawurl string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(rawurl))
+	if err != nil {
+		return "", false
+	}
+	u = plusBase.ResolveReference(u)
+	switch u.Scheme {
+	case "http", "https", "mailto":
+		return u.String(), true
+	}
+	return "", false
+}
+
+// isTrackingPixel reports whether the img token is too small to see.
+func isTrackingPixel(t html.Token) bool {
+	for _, a := range t.Attr {
+		if (a.Key == "width" || a.Key == "height") && (a.Val == "0" || a.Val == "1") {
+			return true
+		}
+	}
+	return false
+}
+
+func isVoid(tag string) bool {
+	return tag == "br" || tag == "img"
+}
+
+func hasAttr(attrs []html.Attribute, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(ss []string, s string) bool {
+	for _, t := range ss {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"plain text", "plain text"},
+		{"a <b>bold</b> move<br />", "a <b>bold</b> move<br />"},
+		{"1 &lt; 2 &amp; 3", "1 &lt; 2 &amp; 3"},
+		{`hi<script>alert("x")</script> there`, "hi there"},
+		{`<style>b { color: red }</style>styled`, "styled"},
+		{`<b onclick="steal()" style="color: red">click</b>`, "<b>click</b>"},
+		{`<a href="javascript:steal()">link</a>`, "<a>link</a>"},
+		{`<a href="./116810148281701144465" oid="116810148281701144465">+Russ Cox</a>`,
+			`<a href="https://plus.google.com/116810148281701144465">+Russ Cox</a>`},
+		{`<a href="/+RobPike" title="Rob">Rob</a>`, `<a href="https://plus.google.com/+RobPike" title="Rob">Rob</a>`},
+		{`<a href="http://swtch.com/">swtch</a>`, `<a href="http://swtch.com/">swtch</a>`},
+		{`<img src="https://t.example.com/p.gif" width="1" height="1">seen`, "seen"},
+		{`<img src="https://example.com/a.png" alt="a" onerror="steal()">`, `<img src="https://example.com/a.png" alt="a" />`},
+		{`<img src="javascript:steal()">`, ""},
+		{`<iframe src="https://example.com/"><p>inside</p></iframe>after`, "after"},
+		{`<object><param name="movie" value="x.swf"><embed src="x.swf"></object>flash`, "flash"},
+		{`<div><font color="red">red</font></div>`, "red"},
+		{`<i>unclosed <b>tags`, "<i>unclosed <b>tags</b></i>"},
+		{`<b>crossed <i>tags</b></i>`, "<b>crossed <i>tags</i></b>"},
+		{`stray</b> end`, "stray end"},
+	}
+	for _, tt := range tests {
+		if out := sanitizeHTML(tt.in); out != tt.out {
+			t.Errorf("sanitizeHTML(%q): want %q, got %q", tt.in, tt.out, out)
+		}
+	}
+}
+
+func TestHTMLText(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"plain text", "plain text"},
+		{"1 &lt; 2 &amp; 3", "1 < 2 & 3"},
+		{"line one<br />line two", "line one\nline two"},
+		{"<p>one</p><p>two</p>", "one\ntwo"},
+		{`<b>bold</b><script>alert("x")</script> move`, "bold move"},
+	}
+	for _, tt := range tests {
+		if out := htmlText(tt.in); out != tt.out {
+			t.Errorf("htmlText(%q): want %q, got %q", tt.in, tt.out, out)
+		}
+	}
+}
+
+func TestFixtureContentIsSanitized(t *testing.T) {
+	feed, err := fixtureRetriever(t).Find("116810148281701144465")
+	if err != nil {
+		t.Fatalf("unable to Find id: %s", err)
+	}
+	for i, a := range feed.Items() {
+		if h := a.ContentHTML(); strings.Contains(h, " oid=") || strings.Contains(h, " class=") {
+			t.Errorf("item %d: Google+ attributes left in %q", i, h)
+		}
+		if text := a.ContentText(); strings.Contains(text, "<br") || strings.Contains(text, "&amp;") {
+			t.Errorf("item %d: markup left in %q", i, text)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Refresher fetches a user's feed from upstream even if a copy is already
+// stored, and stores the result.
+type Refresher interface {
+	Refresh(string) (Feed, error)
+}
+
+// RefreshScheduler is a FeedStorage that remembers which users' feeds were
+// asked for recently and keeps them fresh in the background, so that readers
+// are served from storage instead of waiting on Google+. Users who post often
+// are refreshed as often as every minInterval, quiet ones as rarely as every
+// maxInterval. Users not asked for in idle are forgotten. No more than budget
+// refreshes are made per minute.
+type RefreshScheduler struct {
+	store       FeedStorage
+	refresher   Refresher
+	minInterval time.Duration
+	maxInterval time.Duration
+	idle        time.Duration
+	budget      int
+	lg          *log.Logger
+	now         func() time.Time
+
+	mu   sync.Mutex
+	subs map[string]*subscription
+}
+
+type subscription struct {
+	userId        string
+	lastRequested time.Time
+	next          time.Time
+	interval      time.Duration
+	newest        time.Time // the newest post seen so far
+}
+
+func NewRefreshScheduler(fs FeedStorage, r Refresher, minInterval, maxInterval, idle time.Duration, budget int, lg *log.Logger) *RefreshScheduler {
+	return &RefreshScheduler{
+		store:       fs,
+		refresher:   r,
+		minInterval: minInterval,
+		maxInterval: maxInterval,
+		idle:        idle,
+		budget:      budget,
+		lg:          lg,
+		now:         time.Now,
+		subs:        make(map[string]*subscription),
+	}
+}
+
+// Find subscribes to the user's feed once it has been found, so that ids
+// that don't exist don't use up the budget, and forgets users who are no
+// longer found.
+func (s *RefreshScheduler) Find(userId string) (Feed, error) {
+	feed, err := s.store.Find(userId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	sub, ok := s.subs[userId]
+	switch {
+	case isNotFound(err):
+		delete(s.subs, userId)
+		return feed, err
+	case !ok && err != nil:
+		return feed, err
+	case !ok:
+		sub = &subscription{userId: userId, interval: s.minInterval, next: now.Add(s.minInterval)}
+		s.subs[userId] = sub
+	}
+	sub.lastRequested = now
+	return feed, err
+}
+
+// Run refreshes feeds as they come due, one at a time at the pace the budget
+// allows. It never returns.
+func (s *RefreshScheduler) Run() {
+	t := time.NewTicker(time.Minute / time.Duration(s.budget))
+	defer t.Stop()
+	for range t.C {
+		s.refreshNext()
+	}
+}
+
+// QueueDepth is the number of feeds due for a refresh that haven't had one.
+func (s *RefreshScheduler) QueueDepth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	n := 0
+	for _, sub := range s.subs {
+		if !sub.next.After(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// Subscriptions is the number of feeds being kept fresh.
+func (s *RefreshScheduler) Subscriptions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs)
+}
+
+// refreshNext refreshes the feed that has been due the longest, if any.
+func (s *RefreshScheduler) refreshNext() {
+	s.mu.Lock()
+	now := s.now()
+	var due *subscription
+	for id, sub := range s.subs {
+		if now.Sub(sub.lastRequested) > s.idle {
+			delete(s.subs, id)
+			continue
+		}
+		if !sub.next.After(now) && (due == nil || sub.next.Before(due.next)) {
+			due = sub
+		}
+	}
+	s.mu.Unlock()
+	if due == nil {
+		return
+	}
+
+	scheduledRefreshes.Inc(1)
+	feed, err := s.refresher.Refresh(due.userId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if isNotFound(err) {
+		delete(s.subs, due.userId)
+		return
+	}
+	if err != nil {
+		scheduledRefreshFailures.Inc(1)
+		s.lg.Printf("ERROR scheduled refresh of %s: %s", due.userId, err)
+	} else {
+		s.adjust(due, feed)
+	}
+	due.next = s.now().Add(due.interval)
+}
+
+// adjust halves the subscription's interval if the feed has a post newer than
+// the last refresh saw, and doubles it otherwise.
+func (s *RefreshScheduler) adjust(sub *subscription, feed Feed) {
+	var newest time.Time
+	for _, a := range feed.Items() {
+		if p := parseTime(a.Published()); p.After(newest) {
+			newest = p
+		}
+	}
+	if newest.After(sub.newest) {
+		sub.interval /= 2
+	} else {
+		sub.interval *= 2
+	}
+	if sub.interval < s.minInterval {
+		sub.interval = s.minInterval
+	}
+	if sub.interval > s.maxInterval {
+		sub.interval = s.maxInterval
+	}
+	if newest.After(sub.newest) {
+		sub.newest = newest
+	}
+}
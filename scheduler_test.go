@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	plus "google.golang.org/api/plus/v1"
+)
+
+// fakeRefresher returns a feed whose only post was published at newest, or
+// err if it is set.
+type fakeRefresher struct {
+	newest    time.Time
+	err       error
+	refreshes int
+}
+
+func (r *fakeRefresher) Refresh(userId string) (Feed, error) {
+	r.refreshes++
+	if r.err != nil {
+		return nil, r.err
+	}
+	a := &plus.Activity{Published: r.newest.Format(time.RFC3339), Object: &plus.ActivityObject{}}
+	return &ActorFeed{&plus.Person{Id: userId}, &plus.ActivityFeed{Items: []*plus.Activity{a}}}, nil
+}
+
+func TestSchedulerAdaptsInterval(t *testing.T) {
+	now := time.Now()
+	fr := &fakeRefresher{newest: now}
+	rs := NewRefreshScheduler(&countingStorage{}, fr, time.Minute, 8*time.Minute, time.Hour, 60, nullLog())
+	rs.now = func() time.Time { return now }
+
+	rs.Find("1")
+	rs.refreshNext()
+	if fr.refreshes != 0 {
+		t.Fatalf("refreshed before the feed was due")
+	}
+	if rs.QueueDepth() != 0 {
+		t.Errorf("QueueDepth: want 0, got %d", rs.QueueDepth())
+	}
+
+	// No new posts after the first refresh, so the interval should back
+	// off: 1m, 2m, 4m, 8m, 8m.
+	for _, want := range []time.Duration{1, 2, 4, 8, 8} {
+		now = rs.subs["1"].next
+		if rs.QueueDepth() != 1 {
+			t.Errorf("QueueDepth: want 1, got %d", rs.QueueDepth())
+		}
+		rs.refreshNext()
+		if got := rs.subs["1"].interval; got != want*time.Minute {
+			t.Errorf("interval: want %s, got %s", want*time.Minute, got)
+		}
+	}
+
+	fr.newest = now
+	now = rs.subs["1"].next
+	rs.refreshNext()
+	if got := rs.subs["1"].interval; got != 4*time.Minute {
+		t.Errorf("after a new post, interval: want 4m, got %s", got)
+	}
+}
+
+func TestSchedulerForgetsIdleAndMissingFeeds(t *testing.T) {
+	now := time.Now()
+	fr := &fakeRefresher{newest: now}
+	rs := NewRefreshScheduler(&countingStorage{}, fr, time.Minute, time.Hour, time.Hour, 60, nullLog())
+	rs.now = func() time.Time { return now }
+
+	rs.Find("1")
+	now = now.Add(2 * time.Hour)
+	rs.refreshNext()
+	if rs.Subscriptions() != 0 || fr.refreshes != 0 {
+		t.Errorf("idle feed was kept or refreshed")
+	}
+
+	rs.Find("2")
+	now = now.Add(time.Minute)
+	fr.err = &googleapi.Error{Code: http.StatusNotFound}
+	rs.refreshNext()
+	if rs.Subscriptions() != 0 {
+		t.Errorf("404ed feed was kept")
+	}
+}
+
+func TestSchedulerSubscribesOnlyToFoundFeeds(t *testing.T) {
+	cs := &countingStorage{err: &googleapi.Error{Code: http.StatusNotFound}}
+	rs := NewRefreshScheduler(cs, &fakeRefresher{}, time.Minute, time.Hour, time.Hour, 60, nullLog())
+
+	rs.Find("404")
+	if rs.Subscriptions() != 0 {
+		t.Errorf("subscribed to a feed that wasn't found")
+	}
+
+	cs.err = nil
+	rs.Find("1")
+	if rs.Subscriptions() != 1 {
+		t.Fatalf("want a subscription to the found feed, got %d", rs.Subscriptions())
+	}
+
+	cs.err = &googleapi.Error{Code: http.StatusInternalServerError}
+	rs.Find("2")
+	rs.Find("1")
+	if rs.Subscriptions() != 1 {
+		t.Errorf("a failing Find changed the subscriptions: got %d", rs.Subscriptions())
+	}
+
+	cs.err = &googleapi.Error{Code: http.StatusNotFound}
+	rs.Find("1")
+	if rs.Subscriptions() != 0 {
+		t.Errorf("kept the subscription to a feed no longer found")
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	plus "google.golang.org/api/plus/v1"
+)
+
+// maxQueryLen is the longest search query, in characters, that's sent to
+// Google+.
+const maxQueryLen = 100
+
+var (
+	ErrBadQuery    = errors.New("search query is empty or too long")
+	ErrRateLimited = errors.New("too many searches")
+)
+
+// SearchStorage finds the feed of the public posts matching a query.
+type SearchStorage interface {
+	Search(query string) (Feed, error)
+}
+
+// normalizeQuery trims the query, squeezes its whitespace and lowercases
+// it, since Google+ search doesn't care about case, so that queries that
+// are the same search share a cache entry and a rate limit. It returns ""
+// if the query is empty or longer than maxQueryLen.
+func normalizeQuery(q string) string {
+	q = strings.ToLower(strings.Join(strings.Fields(q), " "))
+	if utf8.RuneCountInString(q) > maxQueryLen {
+		return ""
+	}
+	return q
+}
+
+// Search runs a public search of Google+, newest posts first.
+func (f *FeedRetriever) Search(query string) (Feed, error) {
+	searchAttempts.Inc(1)
+	f.lg.Printf("Search Public Activities: %q", query)
+	feed, err := f.client.Activities.Search(query).OrderBy("recent").MaxResults(20).Do()
+	if err != nil {
+		searchFailures.Inc(1)
+		return nil, err
+	}
+	return &SearchFeed{query, feed}, nil
+}
+
+// SearchFeed is the Feed of a search's results. Its ActorName describes the
+// search, and it has no ActorId.
+type SearchFeed struct {
+	query string
+	feed  *plus.ActivityFeed
+}
+
+func (s *SearchFeed) Title() string {
+	return s.ActorName()
+}
+
+// Id has the query escaped, so that it makes a valid tag: URI.
+func (s *SearchFeed) Id() string {
+	return "plus2rss-search-" + url.QueryEscape(s.query)
+}
+
+func (s *SearchFeed) Updated() string {
+	return s.feed.Updated
+}
+
+func (s *SearchFeed) Items() []Activity {
+	acts := make([]Activity, len(s.feed.Items))
+	for i, a := range s.feed.Items {
+		acts[i] = &JSONActivity{*a}
+	}
+	return acts
+}
+
+func (s *SearchFeed) ActorName() string {
+	return `Search for "` + s.query + `"`
+}
+
+func (s *SearchFeed) ActorId() string {
+	return ""
+}
+
+// CachingSearchStorage wraps another SearchStorage, normalizing queries,
+// caching their results for ttl, and limiting how often Google+ is searched.
+// A query is sent upstream at most once per queryInterval, and no more than
+// perMinute queries are sent upstream a minute in all. Searches for a query
+// that's already being sent upstream wait for its results. Within
+// queryInterval, the last results found for a query are served once they've
+// left the cache. Searches over either limit with no results to serve fail
+// with ErrRateLimited.
+type CachingSearchStorage struct {
+	store  SearchStorage
+	cache  *ttlCache
+	recent *ttlCache
+	global *tokenBucket
+
+	mu sync.Mutex // held while checking and adding to recent
+}
+
+// recentSearch is a query sent upstream within the query interval. Its
+// feed and err are set before wg is done.
+type recentSearch struct {
+	wg   sync.WaitGroup
+	feed Feed
+	err  error
+}
+
+func NewCachingSearchStorage(ss SearchStorage, ttl, queryInterval time.Duration, perMinute, maxEntries int) *CachingSearchStorage {
+	return &CachingSearchStorage{
+		store:  ss,
+		cache:  newTTLCache(ttl, maxEntries, searchCacheEvictions),
+		recent: newTTLCache(queryInterval, maxEntries, searchRecentEvictions),
+		global: newTokenBucket(perMinute, time.Minute),
+	}
+}
+
+func (c *CachingSearchStorage) Search(query string) (Feed, error) {
+	query = normalizeQuery(query)
+	if query == "" {
+		return nil, ErrBadQuery
+	}
+	if v, ok := c.cache.Get(query); ok {
+		searchCacheHits.Inc(1)
+		return v.(Feed), nil
+	}
+	searchCacheMisses.Inc(1)
+
+	c.mu.Lock()
+	if v, ok := c.recent.Get(query); ok {
+		c.mu.Unlock()
+		rs := v.(*recentSearch)
+		rs.wg.Wait()
+		if rs.err != nil {
+			searchRateLimited.Inc(1)
+			return nil, ErrRateLimited
+		}
+		searchRecentHits.Inc(1)
+		return rs.feed, nil
+	}
+	if !c.global.Take() {
+		c.mu.Unlock()
+		searchRateLimited.Inc(1)
+		return nil, ErrRateLimited
+	}
+	rs := &recentSearch{}
+	rs.wg.Add(1)
+	c.recent.Add(query, rs)
+	c.mu.Unlock()
+
+	rs.feed, rs.err = c.store.Search(query)
+	rs.wg.Done()
+	if rs.err != nil {
+		return nil, rs.err
+	}
+	c.cache.Add(query, rs.feed)
+	return rs.feed, nil
+}
+
+// tokenBucket allows n events per period, refilling continuously, with
+// bursts of up to n. It is safe for concurrent use.
+type tokenBucket struct {
+	n      float64
+	period time.Duration
+	now    func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(n int, period time.Duration) *tokenBucket {
+	return &tokenBucket{n: float64(n), period: period, now: time.Now, tokens: float64(n)}
+}
+
+// Take reports whether an event is allowed now, and counts it if so.
+func (b *tokenBucket) Take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens += b.n * float64(now.Sub(b.last)) / float64(b.period)
+		if b.tokens > b.n {
+			b.tokens = b.n
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	plus "google.golang.org/api/plus/v1"
+)
+
+// searchCounter is a SearchStorage that counts the searches it's asked to
+// run and fails them with err if it's set.
+type searchCounter struct {
+	mu      sync.Mutex
+	queries map[string]int
+	err     error
+}
+
+func (s *searchCounter) Search(query string) (Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.queries == nil {
+		s.queries = make(map[string]int)
+	}
+	s.queries[query]++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &SearchFeed{query, &plus.ActivityFeed{}}, nil
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	queries := map[string]string{
+		"golang":                        "golang",
+		"  Go   Programming\tLanguage ": "go programming language",
+		"":                              "",
+		"   ":                           "",
+		strings.Repeat("a", 100):        strings.Repeat("a", 100),
+		strings.Repeat("a", 101):        "",
+	}
+	for in, out := range queries {
+		if q := normalizeQuery(in); q != out {
+			t.Errorf("normalizeQuery(%q): want %q, got %q", in, out, q)
+		}
+	}
+}
+
+func TestSearchCaching(t *testing.T) {
+	sc := &searchCounter{}
+	c := NewCachingSearchStorage(sc, time.Minute, 5*time.Minute, 10, 10)
+	now := time.Now()
+	c.cache.now = func() time.Time { return now }
+	c.recent.now = func() time.Time { return now }
+
+	for _, q := range []string{"golang", " GoLang ", "golang"} {
+		feed, err := c.Search(q)
+		if err != nil {
+			t.Fatalf("Search(%q): %s", q, err)
+		}
+		if feed.Id() != "plus2rss-search-golang" {
+			t.Errorf("Search(%q): got feed %q", q, feed.Id())
+		}
+	}
+	if sc.queries["golang"] != 1 {
+		t.Errorf("want 1 upstream search, got %d", sc.queries["golang"])
+	}
+
+	// The results have expired, but the query was sent too recently to be
+	// sent again, so the last results are served.
+	now = now.Add(2 * time.Minute)
+	if feed, err := c.Search("golang"); err != nil || feed.Id() != "plus2rss-search-golang" {
+		t.Errorf("want the last results, got %v, %v", feed, err)
+	}
+	if sc.queries["golang"] != 1 {
+		t.Errorf("want 1 upstream search, got %d", sc.queries["golang"])
+	}
+	now = now.Add(5 * time.Minute)
+	if _, err := c.Search("golang"); err != nil {
+		t.Errorf("after the query interval: %s", err)
+	}
+	if sc.queries["golang"] != 2 {
+		t.Errorf("want 2 upstream searches, got %d", sc.queries["golang"])
+	}
+
+	feed, err := c.Search("Go <3 #golang")
+	if err != nil {
+		t.Fatalf("Search: %s", err)
+	}
+	if feed.Id() != "plus2rss-search-go+%3C3+%23golang" {
+		t.Errorf("want the query escaped in the feed id, got %q", feed.Id())
+	}
+
+	if _, err := c.Search("  "); err != ErrBadQuery {
+		t.Errorf("empty query: want ErrBadQuery, got %v", err)
+	}
+}
+
+func TestSearchFailuresAreRateLimited(t *testing.T) {
+	sc := &searchCounter{err: errors.New("quota")}
+	c := NewCachingSearchStorage(sc, time.Minute, time.Minute, 10, 10)
+	for i := 0; i < 3; i++ {
+		c.Search("golang")
+	}
+	if sc.queries["golang"] != 1 {
+		t.Errorf("a failing query was sent upstream %d times", sc.queries["golang"])
+	}
+}
+
+// blockingSearch is a SearchStorage whose searches wait for release, so
+// that concurrent searches can be made while one is running.
+type blockingSearch struct {
+	searchCounter
+	started chan bool
+	release chan bool
+}
+
+func (b *blockingSearch) Search(query string) (Feed, error) {
+	b.started <- true
+	<-b.release
+	return b.searchCounter.Search(query)
+}
+
+func TestSearchCoalescing(t *testing.T) {
+	b := &blockingSearch{started: make(chan bool, 10), release: make(chan bool)}
+	c := NewCachingSearchStorage(b, time.Minute, time.Minute, 10, 10)
+
+	errs := make(chan error, 3)
+	search := func() {
+		_, err := c.Search("golang")
+		errs <- err
+	}
+	go search()
+	<-b.started
+	go search()
+	go search()
+	// The others either wait on the first search or, if they start after
+	// it's done, are served its results.
+	close(b.release)
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("search %d: %s", i, err)
+		}
+	}
+	if b.queries["golang"] != 1 {
+		t.Errorf("want 1 upstream search, got %d", b.queries["golang"])
+	}
+}
+
+func TestSearchGlobalRateLimit(t *testing.T) {
+	sc := &searchCounter{}
+	c := NewCachingSearchStorage(sc, time.Minute, time.Minute, 2, 10)
+	now := time.Now()
+	c.global.now = func() time.Time { return now }
+
+	for _, q := range []string{"a", "b"} {
+		if _, err := c.Search(q); err != nil {
+			t.Fatalf("Search(%q): %s", q, err)
+		}
+	}
+	if _, err := c.Search("c"); err != ErrRateLimited {
+		t.Errorf("third search in a minute: want ErrRateLimited, got %v", err)
+	}
+	if _, err := c.Search("a"); err != nil {
+		t.Errorf("cached search: %s", err)
+	}
+	now = now.Add(30 * time.Second)
+	if _, err := c.Search("c"); err != nil {
+		t.Errorf("after the bucket refilled: %s", err)
+	}
+}
+
+func TestRetrieverSearch(t *testing.T) {
+	tr := &FakeClientTransport{}
+	u, _ := url.Parse("https://www.googleapis.com/plus/v1/activities?alt=json&maxResults=20&orderBy=recent&query=golang")
+	tr.Add(u, "GET", feedResp.Response)
+	srv, err := plus.New(&http.Client{Transport: tr})
+	if err != nil {
+		t.Fatalf("unable to make Google+ client: %s", err)
+	}
+	feed, err := (&FeedRetriever{srv, nullLog()}).Search("golang")
+	if err != nil {
+		t.Fatalf("Search: %s", err)
+	}
+	if len(feed.Items()) != 20 || feed.ActorName() != `Search for "golang"` {
+		t.Errorf("got %d items named %q", len(feed.Items()), feed.ActorName())
+	}
+}
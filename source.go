@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+)
+
+// The kinds of Google+ thing a feed can be made of.
+const (
+	SourceUser       = "user"
+	SourceCollection = "collection"
+	SourceCommunity  = "community"
+)
+
+// ErrUnsupportedSource is returned for sources that Google+ has no API to
+// list the posts of.
+var ErrUnsupportedSource = errors.New("Google+ has no API for the posts of this kind of source")
+
+var (
+	collectionUrlR = regexp.MustCompile(`^https?://plus.google.com/(?:u/\d+/)?collection/([A-Za-z0-9_-]+)`)
+	communityUrlR  = regexp.MustCompile(`^https?://plus.google.com/(?:u/\d+/)?communities/(\d+)`)
+	collectionIdR  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	communityIdR   = regexp.MustCompile(`^\d+$`)
+)
+
+// Source is a user, collection, community or the like that a feed can be
+// made of. Kind is the Kind of its SourceType.
+type Source struct {
+	Kind string
+	Id   string
+}
+
+// SourceStorage finds the feed of any kind of Source.
+type SourceStorage interface {
+	FindSource(Source) (Feed, error)
+}
+
+// SourceType is a kind of Source, as registered with a SourceRegistry.
+// Parse finds the id in a URL, or in an id typed in as it is, and returns ""
+// if it can't. ValidId reports whether an id from one of its feed's paths
+// could be one. The feed of the Source with a given id is served at
+// Prefix/ID, and found with Find. Unsupported kinds are ones whose feeds
+// can't be found yet, which the front page says instead of linking to them.
+type SourceType struct {
+	Kind        string
+	Prefix      string
+	Parse       func(urlOrId string) string
+	ValidId     func(id string) bool
+	Find        func(id string) (Feed, error)
+	Unsupported bool
+}
+
+// SourceRegistry is every SourceType the server knows, in the order their
+// Parse funcs are tried. It is the SourceStorage for all of them. It must
+// not be changed once the frontend has been made with it.
+type SourceRegistry struct {
+	types  []*SourceType
+	byKind map[string]*SourceType
+}
+
+func NewSourceRegistry() *SourceRegistry {
+	return &SourceRegistry{byKind: make(map[string]*SourceType)}
+}
+
+// Register adds st, replacing any SourceType of the same Kind.
+func (r *SourceRegistry) Register(st *SourceType) {
+	if old, ok := r.byKind[st.Kind]; ok {
+		for i, t := range r.types {
+			if t == old {
+				r.types = append(r.types[:i], r.types[i+1:]...)
+				break
+			}
+		}
+	}
+	r.types = append(r.types, st)
+	r.byKind[st.Kind] = st
+}
+
+// Types are the registered SourceTypes, in the order they were registered.
+func (r *SourceRegistry) Types() []*SourceType {
+	return r.types
+}
+
+// Parse works out which Source a URL, or an id, is of. It returns false if
+// no SourceType can tell.
+func (r *SourceRegistry) Parse(urlOrId string) (Source, bool) {
+	for _, st := range r.types {
+		if id := st.Parse(urlOrId); id != "" {
+			return Source{st.Kind, id}, true
+		}
+	}
+	return Source{}, false
+}
+
+// Path is where the frontend serves the feed of src.
+func (r *SourceRegistry) Path(src Source) string {
+	st, ok := r.byKind[src.Kind]
+	if !ok {
+		return ""
+	}
+	return st.Prefix + "/" + src.Id
+}
+
+// Supported reports whether the feed of src can be found.
+func (r *SourceRegistry) Supported(src Source) bool {
+	st, ok := r.byKind[src.Kind]
+	return ok && !st.Unsupported
+}
+
+func (r *SourceRegistry) FindSource(src Source) (Feed, error) {
+	st, ok := r.byKind[src.Kind]
+	if !ok {
+		return nil, errors.New("unknown kind of source: " + src.Kind)
+	}
+	return st.Find(src.Id)
+}
+
+// NewPlusSources is a SourceRegistry of the kinds of Google+ source. Users'
+// feeds come from fs. The Google+ API has no way to list the posts in a
+// collection or a community, so those are Unsupported, and their feeds
+// ErrUnsupportedSource, until it does. Collections and communities are
+// registered first, since their URLs would otherwise be mistaken for a
+// user's.
+func NewPlusSources(fs FeedStorage) *SourceRegistry {
+	r := NewSourceRegistry()
+	r.Register(&SourceType{
+		Kind:        SourceCollection,
+		Prefix:      "/collection",
+		Parse:       regexpParser(collectionUrlR),
+		ValidId:     collectionIdR.MatchString,
+		Find:        unsupportedSource,
+		Unsupported: true,
+	})
+	r.Register(&SourceType{
+		Kind:        SourceCommunity,
+		Prefix:      "/community",
+		Parse:       regexpParser(communityUrlR),
+		ValidId:     communityIdR.MatchString,
+		Find:        unsupportedSource,
+		Unsupported: true,
+	})
+	r.Register(&SourceType{
+		Kind:    SourceUser,
+		Prefix:  "/u",
+		Parse:   PlausibleUserId,
+		ValidId: func(id string) bool { return PlausibleUserId(id) == id },
+		Find:    fs.Find,
+	})
+	return r
+}
+
+// regexpParser is a SourceType Parse func for URLs that re captures the id
+// in.
+func regexpParser(re *regexp.Regexp) func(string) string {
+	return func(urlOrId string) string {
+		if m := re.FindStringSubmatch(urlOrId); m != nil {
+			return m[1]
+		}
+		return ""
+	}
+}
+
+func unsupportedSource(id string) (Feed, error) {
+	unsupportedSourceFinds.Inc(1)
+	return nil, ErrUnsupportedSource
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		in  string
+		src Source
+		ok  bool
+	}{
+		{"116810148281701144465", Source{SourceUser, "116810148281701144465"}, true},
+		{"https://plus.google.com/116810148281701144465/posts", Source{SourceUser, "116810148281701144465"}, true},
+		{"https://plus.google.com/+RussCox", Source{SourceUser, "+RussCox"}, true},
+		{"https://plus.google.com/collection/QaXrV", Source{SourceCollection, "QaXrV"}, true},
+		{"https://plus.google.com/u/0/collection/Qa-X_rV", Source{SourceCollection, "Qa-X_rV"}, true},
+		{"https://plus.google.com/communities/101996519396366113329", Source{SourceCommunity, "101996519396366113329"}, true},
+		{"https://plus.google.com/u/1/communities/101996519396366113329/stream/abc", Source{SourceCommunity, "101996519396366113329"}, true},
+		{"https://plus.google.com/communities/golang", Source{}, false},
+		{"https://example.com/collection/QaXrV", Source{}, false},
+		{"", Source{}, false},
+	}
+	sources := NewPlusSources(&countingStorage{})
+	for _, tt := range tests {
+		src, ok := sources.Parse(tt.in)
+		if src != tt.src || ok != tt.ok {
+			t.Errorf("Parse(%q): want %+v %t, got %+v %t", tt.in, tt.src, tt.ok, src, ok)
+		}
+	}
+}
+
+func TestSourcePath(t *testing.T) {
+	sources := NewPlusSources(&countingStorage{})
+	paths := map[Source]string{
+		{SourceUser, "1"}:        "/u/1",
+		{SourceCollection, "Qa"}: "/collection/Qa",
+		{SourceCommunity, "2"}:   "/community/2",
+		{"nonesuch", "3"}:        "",
+	}
+	for src, path := range paths {
+		if p := sources.Path(src); p != path {
+			t.Errorf("%+v: want %q, got %q", src, path, p)
+		}
+	}
+}
+
+func TestPlusSources(t *testing.T) {
+	cs := &countingStorage{}
+	sources := NewPlusSources(cs)
+	feed, err := sources.FindSource(Source{SourceUser, "1"})
+	if err != nil || feed.ActorId() != "1" || cs.Calls("1") != 1 {
+		t.Errorf("user: got %v, %v, %d calls", feed, err, cs.Calls("1"))
+	}
+	for _, kind := range []string{SourceCollection, SourceCommunity} {
+		if _, err := sources.FindSource(Source{kind, "1"}); err != ErrUnsupportedSource {
+			t.Errorf("%s: want ErrUnsupportedSource, got %v", kind, err)
+		}
+	}
+	if _, err := sources.FindSource(Source{"nonesuch", "1"}); err == nil {
+		t.Errorf("unknown kind: want an error")
+	}
+}
+
+func TestSourceRegistryRegister(t *testing.T) {
+	sources := NewPlusSources(&countingStorage{})
+	everything := func(s string) string { return s }
+	sources.Register(&SourceType{Kind: "catchall", Prefix: "/all", Parse: everything})
+	// Sources registered later are only tried after the ones before them.
+	if src, _ := sources.Parse("116810148281701144465"); src.Kind != SourceUser {
+		t.Errorf("want a user, got %+v", src)
+	}
+	if src, _ := sources.Parse("anything"); src != (Source{"catchall", "anything"}) {
+		t.Errorf("want the catchall, got %+v", src)
+	}
+
+	// Registering a Kind again replaces it.
+	sources.Register(&SourceType{Kind: SourceCollection, Prefix: "/c2", Parse: everything})
+	if n := len(sources.Types()); n != 4 {
+		t.Errorf("want 4 types, got %d", n)
+	}
+	if p := sources.Path(Source{SourceCollection, "x"}); p != "/c2/x" {
+		t.Errorf("replaced collection: got path %q", p)
+	}
+}
+
+func TestSourceSupported(t *testing.T) {
+	sources := NewPlusSources(&countingStorage{})
+	supported := map[Source]bool{
+		{SourceUser, "1"}:        true,
+		{SourceCollection, "Qa"}: false,
+		{SourceCommunity, "2"}:   false,
+		{"nope", "1"}:            false,
+	}
+	for src, want := range supported {
+		if got := sources.Supported(src); got != want {
+			t.Errorf("%+v: want %t, got %t", src, want, got)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	staleMinRetry = 5 * time.Second
+	staleMaxRetry = 10 * time.Minute
+)
+
+// StaleFeedStorage wraps another FeedStorage and remembers the last Feed
+// successfully found for each user. When a later Find fails with anything but
+// a 404, or takes longer than deadline, the remembered Feed is returned as a
+// *StaleFeed and a background refresh retries the upstream until it answers
+// again.
+type StaleFeedStorage struct {
+	store    FeedStorage
+	deadline time.Duration
+	lg       *log.Logger
+	good     *ttlCache
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+}
+
+// StaleFeed is a Feed that could not be refreshed from upstream. Found is
+// when it was last successfully found.
+type StaleFeed struct {
+	Feed
+	Found time.Time
+}
+
+func (f *StaleFeed) Network() string {
+	return feedNetwork(f.Feed)
+}
+
+type goodFeed struct {
+	feed  Feed
+	found time.Time
+}
+
+type findResult struct {
+	feed Feed
+	err  error
+}
+
+// StaleAge is how long a user's feed has been served stale.
+type StaleAge struct {
+	UserId string
+	Age    time.Duration
+}
+
+func NewStaleFeedStorage(fs FeedStorage, deadline, maxAge time.Duration, maxEntries int, lg *log.Logger) *StaleFeedStorage {
+	return &StaleFeedStorage{
+		store:      fs,
+		deadline:   deadline,
+		lg:         lg,
+		good:       newTTLCache(maxAge, maxEntries, staleEvictions),
+		refreshing: make(map[string]bool),
+	}
+}
+
+func (s *StaleFeedStorage) Find(userId string) (Feed, error) {
+	ch := make(chan findResult, 1)
+	go func() {
+		feed, err := s.find(userId)
+		ch <- findResult{feed, err}
+	}()
+
+	t := time.NewTimer(s.deadline)
+	defer t.Stop()
+	var res findResult
+	select {
+	case res = <-ch:
+		if res.err == nil || isNotFound(res.err) {
+			return res.feed, res.err
+		}
+	case <-t.C:
+	}
+
+	v, ok := s.good.Get(userId)
+	if !ok {
+		if res.err == nil {
+			// Nothing to fall back on, so wait for the upstream.
+			res = <-ch
+		}
+		return res.feed, res.err
+	}
+	g := v.(*goodFeed)
+	staleServed.Inc(1)
+	s.startRefresh(userId)
+	return &StaleFeed{g.feed, g.found}, nil
+}
+
+// StaleAges returns how long each feed currently being refreshed in the
+// background has been stale, oldest first.
+func (s *StaleFeedStorage) StaleAges() []StaleAge {
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.refreshing))
+	for id := range s.refreshing {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+
+	now := time.Now()
+	ages := make([]StaleAge, 0, len(ids))
+	for _, id := range ids {
+		v, ok := s.good.Peek(id)
+		if !ok {
+			continue
+		}
+		ages = append(ages, StaleAge{id, now.Sub(v.(*goodFeed).found)})
+	}
+	sort.Sort(staleAgeSlice(ages))
+	return ages
+}
+
+func (s *StaleFeedStorage) find(userId string) (Feed, error) {
+	feed, err := s.store.Find(userId)
+	if err == nil {
+		found := time.Now()
+		// The cache below returns the same feed until it fetches a new one,
+		// and the feed is only as old as that fetch.
+		if v, ok := s.good.Peek(userId); ok && v.(*goodFeed).feed == feed {
+			found = v.(*goodFeed).found
+		}
+		s.good.Add(userId, &goodFeed{feed, found})
+	} else if isNotFound(err) {
+		s.good.Remove(userId)
+	}
+	return feed, err
+}
+
+func (s *StaleFeedStorage) startRefresh(userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refreshing[userId] {
+		return
+	}
+	s.refreshing[userId] = true
+	go s.refresh(userId)
+}
+
+func (s *StaleFeedStorage) refresh(userId string) {
+	backoff := staleMinRetry
+	for {
+		time.Sleep(backoff)
+		if _, ok := s.good.Get(userId); !ok {
+			// The last good feed has aged out, so there is nothing
+			// left to refresh.
+			break
+		}
+		_, err := s.find(userId)
+		if err == nil || isNotFound(err) {
+			break
+		}
+		staleRefreshFailures.Inc(1)
+		s.lg.Printf("ERROR background refresh of stale feed %s: %s", userId, err)
+		backoff *= 2
+		if backoff > staleMaxRetry {
+			backoff = staleMaxRetry
+		}
+	}
+	s.mu.Lock()
+	delete(s.refreshing, userId)
+	s.mu.Unlock()
+}
+
+type staleAgeSlice []StaleAge
+
+func (s staleAgeSlice) Len() int {
+	return len(s)
+}
+
+func (s staleAgeSlice) Less(i, j int) bool {
+	return s[i].Age > s[j].Age
+}
+
+func (s staleAgeSlice) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestStaleServedOnUpstreamError(t *testing.T) {
+	cs := &countingStorage{}
+	ss := NewStaleFeedStorage(cs, time.Second, time.Hour, 10, nullLog())
+
+	feed, err := ss.Find("1")
+	if err != nil {
+		t.Fatalf("Find: %s", err)
+	}
+	if _, ok := feed.(*StaleFeed); ok {
+		t.Errorf("fresh feed was marked stale")
+	}
+
+	cs.mu.Lock()
+	cs.err = errors.New("boom")
+	cs.mu.Unlock()
+	feed, err = ss.Find("1")
+	if err != nil {
+		t.Fatalf("Find with failing upstream: %s", err)
+	}
+	if _, ok := feed.(*StaleFeed); !ok {
+		t.Errorf("want a *StaleFeed, got %T", feed)
+	}
+	ages := ss.StaleAges()
+	if len(ages) != 1 || ages[0].UserId != "1" {
+		t.Errorf("StaleAges: want only user 1, got %v", ages)
+	}
+
+	_, err = ss.Find("2")
+	if err != cs.err {
+		t.Errorf("with no good copy, want %v, got %v", cs.err, err)
+	}
+}
+
+func TestStaleServedPastDeadline(t *testing.T) {
+	bs := &blockingStorage{started: make(chan bool, 10), release: make(chan bool)}
+	ss := NewStaleFeedStorage(bs, 10*time.Millisecond, time.Hour, 10, nullLog())
+	ss.good.Add("1", &goodFeed{fakeFeed("1"), time.Now()})
+
+	feed, err := ss.Find("1")
+	if err != nil {
+		t.Fatalf("Find: %s", err)
+	}
+	if _, ok := feed.(*StaleFeed); !ok {
+		t.Errorf("want a *StaleFeed, got %T", feed)
+	}
+	close(bs.release)
+}
+
+func TestStaleNotServedFor404(t *testing.T) {
+	cs := &countingStorage{}
+	ss := NewStaleFeedStorage(cs, time.Second, time.Hour, 10, nullLog())
+	ss.Find("1")
+
+	cs.mu.Lock()
+	cs.err = &googleapi.Error{Code: http.StatusNotFound}
+	cs.mu.Unlock()
+	_, err := ss.Find("1")
+	if !isNotFound(err) {
+		t.Errorf("want a 404 error, got %v", err)
+	}
+	if _, ok := ss.good.Get("1"); ok {
+		t.Errorf("good copy of a 404ed feed was kept")
+	}
+}
+
+func TestStaleAgeKeptForCachedFeed(t *testing.T) {
+	cs := &countingStorage{}
+	c := NewCachingFeedStorage(cs, time.Minute, 10)
+	ss := NewStaleFeedStorage(c, time.Second, time.Hour, 10, nullLog())
+	if _, err := ss.Find("1"); err != nil {
+		t.Fatalf("Find: %s", err)
+	}
+	v, _ := ss.good.Peek("1")
+	fetched := time.Now().Add(-30 * time.Second)
+	v.(*goodFeed).found = fetched
+
+	// A cache hit is the same feed, fetched no later than before.
+	if _, err := ss.Find("1"); err != nil {
+		t.Fatalf("Find: %s", err)
+	}
+	v, _ = ss.good.Peek("1")
+	if found := v.(*goodFeed).found; !found.Equal(fetched) {
+		t.Errorf("found of a cached feed moved from %v to %v", fetched, found)
+	}
+
+	// A new fetch is a new feed.
+	c.cache.Remove("1")
+	if _, err := ss.Find("1"); err != nil {
+		t.Fatalf("Find: %s", err)
+	}
+	v, _ = ss.good.Peek("1")
+	if found := v.(*goodFeed).found; !found.After(fetched) {
+		t.Errorf("found of a fetched feed stayed at %v", found)
+	}
+}
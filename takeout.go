@@ -0,0 +1,394 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	plus "google.golang.org/api/plus/v1"
+)
+
+// takeoutTime is the layout of the times in Takeout's post files.
+const takeoutTime = "2006-01-02 15:04:05-0700"
+
+// takeoutPost is a post in a Takeout export's Google+ Stream, as written to
+// one of its JSON files. Reshared posts have the same shape, minus the
+// comments and ACL.
+type takeoutPost struct {
+	URL          string          `json:"url"`
+	ResourceName string          `json:"resourceName"`
+	CreationTime string          `json:"creationTime"`
+	UpdateTime   string          `json:"updateTime"`
+	Author       takeoutPerson   `json:"author"`
+	Content      string          `json:"content"`
+	Link         *takeoutLink    `json:"link"`
+	Media        *takeoutMedia   `json:"media"`
+	Album        *takeoutAlbum   `json:"album"`
+	ResharedPost *takeoutPost    `json:"resharedPost"`
+	Comments     []takeoutPost   `json:"comments"`
+	PlusOnes     []interface{}   `json:"plusOnes"`
+	Reshares     []interface{}   `json:"reshares"`
+	PostACL      *takeoutPostACL `json:"postAcl"`
+}
+
+type takeoutPerson struct {
+	DisplayName    string `json:"displayName"`
+	ProfilePageURL string `json:"profilePageUrl"`
+	AvatarImageURL string `json:"avatarImageUrl"`
+	ResourceName   string `json:"resourceName"`
+}
+
+type takeoutLink struct {
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	ImageURL string `json:"imageUrl"`
+}
+
+// takeoutMedia is a photo or video. LocalFilePath is where the export put
+// its file, relative to the post's.
+type takeoutMedia struct {
+	URL           string `json:"url"`
+	ContentType   string `json:"contentType"`
+	Description   string `json:"description"`
+	Width         int64  `json:"width"`
+	Height        int64  `json:"height"`
+	LocalFilePath string `json:"localFilePath"`
+}
+
+type takeoutAlbum struct {
+	Media []*takeoutMedia `json:"media"`
+}
+
+type takeoutPostACL struct {
+	VisibleToStandardACL *takeoutStandardACL `json:"visibleToStandardAcl"`
+}
+
+type takeoutStandardACL struct {
+	Circles []takeoutCircle `json:"circles"`
+}
+
+type takeoutCircle struct {
+	Type string `json:"type"`
+}
+
+// isPublic reports whether the post was shared with everyone. Takeout
+// exports every post an account could see, but only public ones may be
+// served.
+func (p *takeoutPost) isPublic() bool {
+	if p.PostACL == nil || p.PostACL.VisibleToStandardACL == nil {
+		return false
+	}
+	for _, c := range p.PostACL.VisibleToStandardACL.Circles {
+		if c.Type == "CIRCLE_TYPE_PUBLIC" {
+			return true
+		}
+	}
+	return false
+}
+
+// TakeoutStorage serves the public posts in a Google Takeout export of
+// Google+ Stream, for when there's no Google+ API to ask. The export's post
+// files, in JSON or HTML, are read once, by LoadTakeout, and turned into the
+// same plus types the API hands back, so that their feeds are made the way
+// a FeedRetriever's are.
+//
+// The photos and videos in the export are served by its ServeHTTP, at the
+// mediaBase URL given to LoadTakeout, so that feeds don't depend on Google
+// still hosting them.
+type TakeoutStorage struct {
+	*postIndex
+	media map[string]string // URL path to file
+}
+
+// LoadTakeout reads every post file under dir. Media files are linked to
+// from mediaBase, where ServeHTTP should be mounted.
+func LoadTakeout(dir, mediaBase string, lg *log.Logger) (*TakeoutStorage, error) {
+	t := &TakeoutStorage{newPostIndex("Takeout export"), make(map[string]string)}
+	l := &takeoutLoader{t, dir, mediaBase}
+	var skippedPrivate, skippedOther int
+	// HTML posts know their authors only by their profile URLs, which are
+	// often +Names, so they're added once the JSON posts have said whose
+	// +Names are whose.
+	var htmlPosts []string
+	add := func(p string, read func(string) (*takeoutPost, error)) error {
+		post, err := read(p)
+		if err != nil {
+			return fmt.Errorf("%s: %s", p, err)
+		}
+		if post.ResourceName == "" {
+			// Not a post, but one of the export's other files.
+			skippedOther++
+			return nil
+		}
+		if !post.isPublic() {
+			skippedPrivate++
+			return nil
+		}
+		return l.add(post, filepath.Dir(p))
+	}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".json":
+			return add(p, readTakeoutJSON)
+		case ".html":
+			htmlPosts = append(htmlPosts, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range htmlPosts {
+		err = add(p, readTakeoutHTML)
+		if err != nil {
+			return nil, err
+		}
+	}
+	t.finish()
+	lg.Printf("Takeout: loaded %d public posts of %d users, skipped %d that weren't public and %d other files", len(t.all), len(t.people), skippedPrivate, skippedOther)
+	return t, nil
+}
+
+// readTakeoutJSON reads a post's JSON file. The export's other JSON files,
+// like its circles, which are arrays, don't decode as posts, and give a
+// takeoutPost with no ResourceName.
+func readTakeoutJSON(p string) (*takeoutPost, error) {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	post := &takeoutPost{}
+	err = json.Unmarshal(b, post)
+	if err != nil {
+		return &takeoutPost{}, nil
+	}
+	return post, nil
+}
+
+func readTakeoutHTML(p string) (*takeoutPost, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseTakeoutHTML(f)
+}
+
+// takeoutLoader turns takeoutPosts into plus types as they're read.
+type takeoutLoader struct {
+	t         *TakeoutStorage
+	dir       string
+	mediaBase string
+}
+
+func (l *takeoutLoader) add(post *takeoutPost, postDir string) error {
+	userId := resourceId(post.Author.ResourceName, "users")
+	if userId == "" {
+		return fmt.Errorf("post %s has no author", post.ResourceName)
+	}
+	x := l.t.postIndex
+	if p, ok := x.people[userId]; ok {
+		// HTML posts only know their authors by their +Names.
+		userId = p.Id
+	}
+	published, err := takeoutRFC3339(post.CreationTime)
+	if err != nil {
+		return fmt.Errorf("post %s: %s", post.ResourceName, err)
+	}
+	updated, err := takeoutRFC3339(post.UpdateTime)
+	if err != nil {
+		updated = published
+	}
+
+	a := &plus.Activity{
+		Id:        resourceId(post.ResourceName, "posts"),
+		Url:       post.URL,
+		Published: published,
+		Updated:   updated,
+		Verb:      "post",
+		Actor: &plus.ActivityActor{
+			Id:          userId,
+			DisplayName: post.Author.DisplayName,
+			Url:         post.Author.ProfilePageURL,
+		},
+		Object: &plus.ActivityObject{
+			ObjectType: "note",
+			Content:    post.Content,
+			Url:        post.URL,
+			Replies:    &plus.ActivityObjectReplies{TotalItems: int64(len(post.Comments))},
+			Plusoners:  &plus.ActivityObjectPlusoners{TotalItems: int64(len(post.PlusOnes))},
+			Resharers:  &plus.ActivityObjectResharers{TotalItems: int64(len(post.Reshares))},
+		},
+	}
+	if post.Author.AvatarImageURL != "" {
+		a.Actor.Image = &plus.ActivityActorImage{Url: post.Author.AvatarImageURL}
+	}
+	shown := post
+	if rp := post.ResharedPost; rp != nil {
+		a.Verb = "share"
+		a.Annotation = post.Content
+		a.Object.ObjectType = "activity"
+		a.Object.Content = rp.Content
+		a.Object.Url = rp.URL
+		a.Object.Actor = &plus.ActivityObjectActor{
+			Id:          resourceId(rp.Author.ResourceName, "users"),
+			DisplayName: rp.Author.DisplayName,
+			Url:         rp.Author.ProfilePageURL,
+		}
+		shown = rp
+	}
+	a.Object.Attachments = l.attachments(shown, postDir)
+
+	if _, ok := x.people[userId]; !ok {
+		person := &plus.Person{
+			Id:          userId,
+			DisplayName: post.Author.DisplayName,
+			Url:         post.Author.ProfilePageURL,
+		}
+		// The feeds of users with custom URLs may also be asked for by
+		// their +Name.
+		var aliases []string
+		if name := path.Base(post.Author.ProfilePageURL); strings.HasPrefix(name, "+") {
+			aliases = append(aliases, name)
+		}
+		x.addPerson(person, aliases...)
+	}
+	x.addActivity(a)
+
+	for _, c := range post.Comments {
+		cp, err := takeoutRFC3339(c.CreationTime)
+		if err != nil {
+			continue
+		}
+		x.addComments(a.Id, &plus.Comment{
+			Id:        a.Id + "." + resourceId(c.ResourceName, "comments"),
+			Published: cp,
+			Updated:   cp,
+			Verb:      "post",
+			Actor: &plus.CommentActor{
+				Id:          resourceId(c.Author.ResourceName, "users"),
+				DisplayName: c.Author.DisplayName,
+				Url:         c.Author.ProfilePageURL,
+			},
+			Object: &plus.CommentObject{ObjectType: "comment", Content: c.Content},
+		})
+	}
+	return nil
+}
+
+// attachments are the post's link, photo or video, and album, as the API
+// would have attached them.
+func (l *takeoutLoader) attachments(post *takeoutPost, postDir string) []*plus.ActivityObjectAttachments {
+	var as []*plus.ActivityObjectAttachments
+	if lk := post.Link; lk != nil && lk.URL != "" {
+		a := &plus.ActivityObjectAttachments{ObjectType: "article", DisplayName: lk.Title, Url: lk.URL}
+		if lk.ImageURL != "" {
+			a.Image = &plus.ActivityObjectAttachmentsImage{Url: lk.ImageURL}
+		}
+		as = append(as, a)
+	}
+	if m := post.Media; m != nil {
+		as = append(as, l.mediaAttachment(m, postDir))
+	}
+	if al := post.Album; al != nil && len(al.Media) > 0 {
+		a := &plus.ActivityObjectAttachments{ObjectType: "album", Url: post.URL}
+		for _, m := range al.Media {
+			u, typ := l.mediaURL(m, postDir)
+			a.Thumbnails = append(a.Thumbnails, &plus.ActivityObjectAttachmentsThumbnails{
+				Url:         u,
+				Description: m.Description,
+				Image:       &plus.ActivityObjectAttachmentsThumbnailsImage{Url: u, Type: typ, Width: m.Width, Height: m.Height},
+			})
+		}
+		as = append(as, a)
+	}
+	return as
+}
+
+func (l *takeoutLoader) mediaAttachment(m *takeoutMedia, postDir string) *plus.ActivityObjectAttachments {
+	u, typ := l.mediaURL(m, postDir)
+	if strings.HasPrefix(m.ContentType, "video/") {
+		return &plus.ActivityObjectAttachments{
+			ObjectType:  "video",
+			DisplayName: m.Description,
+			Url:         u,
+			Embed:       &plus.ActivityObjectAttachmentsEmbed{Url: u, Type: typ},
+		}
+	}
+	return &plus.ActivityObjectAttachments{
+		ObjectType:  "photo",
+		DisplayName: m.Description,
+		Url:         u,
+		Image:       &plus.ActivityObjectAttachmentsImage{Url: u, Type: typ, Width: m.Width, Height: m.Height},
+		FullImage:   &plus.ActivityObjectAttachmentsFullImage{Url: u, Type: typ, Width: m.Width, Height: m.Height},
+	}
+}
+
+// mediaURL is where the media's file is served from, if the export has it,
+// or else where Google put it. typ is its MIME type, when that can be told.
+// Takeout gives media types like "image/*", which are no use to anyone.
+func (l *takeoutLoader) mediaURL(m *takeoutMedia, postDir string) (u, typ string) {
+	if !strings.HasSuffix(m.ContentType, "/*") {
+		typ = m.ContentType
+	}
+	if m.LocalFilePath == "" {
+		return m.URL, typ
+	}
+	file := filepath.Join(postDir, filepath.FromSlash(m.LocalFilePath))
+	rel, err := filepath.Rel(l.dir, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return m.URL, typ
+	}
+	if _, err := os.Stat(file); err != nil {
+		return m.URL, typ
+	}
+	p := filepath.ToSlash(rel)
+	l.t.media[p] = file
+	if t := mime.TypeByExtension(path.Ext(p)); t != "" {
+		typ = t
+	}
+	return l.mediaBase + (&url.URL{Path: p}).EscapedPath(), typ
+}
+
+// resourceId is the id after kind in a Takeout resource name, like the
+// user id in "users/123/posts/abc".
+func resourceId(resourceName, kind string) string {
+	parts := strings.Split(resourceName, "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == kind {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+func takeoutRFC3339(s string) (string, error) {
+	t, err := time.Parse(takeoutTime, s)
+	if err != nil {
+		return "", err
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000Z07:00"), nil
+}
+
+// ServeHTTP serves the media files of public posts, and nothing else in
+// the export.
+func (t *TakeoutStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	file, ok := t.media[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, file)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// parseTakeoutHTML reads a post file from a Takeout export made as HTML
+// instead of JSON into the takeoutPost its JSON file would have held, as
+// far as the HTML tells:
+//
+//   - the post's author is its first a.author, whose href is their profile
+//     and whose text is their name,
+//   - a.post-date links to the post, and its text is when it was made,
+//   - .visibility says who it was shared with, "Public" for everyone,
+//   - .main-content is what was posted,
+//   - a.link-embed is a shared link, and the images and videos in .media
+//     are its photos and videos, their src relative to the post file,
+//   - each .comment in .comments has its own a.author, .comment-date and
+//     .comment-content.
+//
+// Files without a post-date link aren't posts, and give a takeoutPost with
+// no ResourceName, like the export's other JSON files.
+func parseTakeoutHTML(r io.Reader) (*takeoutPost, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	post := &takeoutPost{}
+	date := findElement(doc, hasClass("post-date"), hasClass("comments"))
+	if date == nil {
+		return post, nil
+	}
+	post.URL = attr(date, "href")
+	post.CreationTime = strings.TrimSpace(textOf(date))
+	post.UpdateTime = post.CreationTime
+	post.Author = takeoutHTMLAuthor(findElement(doc, hasClass("author"), hasClass("comments")))
+	postId := path.Base(post.URL)
+	if post.URL == "" || postId == "/" || post.Author.ResourceName == "" {
+		return post, nil
+	}
+	post.ResourceName = post.Author.ResourceName + "/posts/" + postId
+
+	if v := findElement(doc, hasClass("visibility"), nil); v != nil && strings.EqualFold(strings.TrimSpace(textOf(v)), "public") {
+		post.PostACL = &takeoutPostACL{&takeoutStandardACL{[]takeoutCircle{{"CIRCLE_TYPE_PUBLIC"}}}}
+	}
+	if c := findElement(doc, hasClass("main-content"), nil); c != nil {
+		post.Content = innerHTML(c)
+	}
+	if lk := findElement(doc, hasClass("link-embed"), hasClass("comments")); lk != nil {
+		post.Link = &takeoutLink{Title: strings.TrimSpace(textOf(lk)), URL: attr(lk, "href")}
+	}
+	if m := findElement(doc, hasClass("media"), hasClass("comments")); m != nil {
+		post.Album = &takeoutAlbum{}
+		for _, n := range findElements(m, isMediaElement, nil) {
+			post.Album.Media = append(post.Album.Media, takeoutHTMLMedia(n))
+		}
+		// A single photo or video is posted as itself, not as an album.
+		if len(post.Album.Media) == 1 {
+			post.Media, post.Album = post.Album.Media[0], nil
+		}
+	}
+
+	if cs := findElement(doc, hasClass("comments"), nil); cs != nil {
+		for i, c := range findElements(cs, hasClass("comment"), nil) {
+			comment := takeoutPost{
+				ResourceName: post.ResourceName + "/comments/" + strconv.Itoa(i),
+				Author:       takeoutHTMLAuthor(findElement(c, hasClass("author"), nil)),
+			}
+			if d := findElement(c, hasClass("comment-date"), nil); d != nil {
+				comment.CreationTime = strings.TrimSpace(textOf(d))
+			}
+			if cc := findElement(c, hasClass("comment-content"), nil); cc != nil {
+				comment.Content = innerHTML(cc)
+			}
+			post.Comments = append(post.Comments, comment)
+		}
+	}
+	return post, nil
+}
+
+// takeoutHTMLAuthor is the person an a.author links to. Their id is the
+// last part of their profile's URL, which is their +Name if they had one.
+func takeoutHTMLAuthor(a *html.Node) takeoutPerson {
+	if a == nil {
+		return takeoutPerson{}
+	}
+	p := takeoutPerson{DisplayName: strings.TrimSpace(textOf(a)), ProfilePageURL: attr(a, "href")}
+	u, err := url.Parse(p.ProfilePageURL)
+	if err != nil {
+		return p
+	}
+	if id := path.Base(u.Path); id != "/" && id != "." {
+		p.ResourceName = "users/" + id
+	}
+	return p
+}
+
+func takeoutHTMLMedia(n *html.Node) *takeoutMedia {
+	m := &takeoutMedia{Description: attr(n, "alt"), ContentType: "video/*"}
+	if n.Data == "img" {
+		m.ContentType = "image/*"
+	}
+	m.Width, _ = strconv.ParseInt(attr(n, "width"), 10, 64)
+	m.Height, _ = strconv.ParseInt(attr(n, "height"), 10, 64)
+	src := attr(n, "src")
+	if u, err := url.Parse(src); err == nil && u.IsAbs() {
+		m.URL = src
+	} else {
+		m.LocalFilePath, _ = url.PathUnescape(src)
+	}
+	return m
+}
+
+func isMediaElement(n *html.Node) bool {
+	return n.Type == html.ElementNode && (n.Data == "img" || n.Data == "video")
+}
+
+func hasClass(class string) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return false
+		}
+		for _, c := range strings.Fields(attr(n, "class")) {
+			if c == class {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// findElement is the first node under n, in document order, that match
+// is true of, without looking inside the nodes skip is true of.
+func findElement(n *html.Node, match, skip func(*html.Node) bool) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if match(c) {
+			return c
+		}
+		if skip != nil && skip(c) {
+			continue
+		}
+		if found := findElement(c, match, skip); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// findElements is every node under n that match is true of, without looking
+// inside the ones it's true of or the ones skip is true of.
+func findElements(n *html.Node, match, skip func(*html.Node) bool) []*html.Node {
+	var found []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch {
+		case match(c):
+			found = append(found, c)
+		case skip != nil && skip(c):
+		default:
+			found = append(found, findElements(c, match, skip)...)
+		}
+	}
+	return found
+}
+
+func textOf(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textOf(c))
+	}
+	return b.String()
+}
+
+// innerHTML is the HTML of n's children, which sanitizeHTML cleans up when
+// the post is shown.
+func innerHTML(n *html.Node) string {
+	var buf bytes.Buffer
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		html.Render(&buf, c)
+	}
+	return strings.TrimSpace(buf.String())
+}
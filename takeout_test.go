@@ -0,0 +1,181 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const takeoutMediaBase = "http://" + testHost + "/takeout/"
+
+func loadTestTakeout(t *testing.T) *TakeoutStorage {
+	ts, err := LoadTakeout("./testdata/takeout", takeoutMediaBase, nullLog())
+	if err != nil {
+		t.Fatalf("LoadTakeout: %s", err)
+	}
+	return ts
+}
+
+func TestTakeoutFind(t *testing.T) {
+	ts := loadTestTakeout(t)
+	for _, userId := range []string{"111111111111111111111", "+AliceExample"} {
+		feed, err := ts.Find(userId)
+		if err != nil {
+			t.Fatalf("Find(%q): %s", userId, err)
+		}
+		if feed.Id() != "plus2rss-111111111111111111111" || feed.ActorName() != "Alice Example" {
+			t.Errorf("Find(%q): got feed %q of %q", userId, feed.Id(), feed.ActorName())
+		}
+		// The friends-only posts must not be served.
+		if n := len(feed.Items()); n != 4 {
+			t.Errorf("Find(%q): want 4 public posts, got %d", userId, n)
+		}
+	}
+
+	if _, err := ts.Find("222222222222222222222"); !isNotFound(err) {
+		t.Errorf("user without posts: want not found, got %v", err)
+	}
+}
+
+func TestTakeoutActivities(t *testing.T) {
+	feed, err := loadTestTakeout(t).Find("111111111111111111111")
+	if err != nil {
+		t.Fatalf("Find: %s", err)
+	}
+	if feed.Updated() != "2018-10-08T17:10:00.000Z" {
+		t.Errorf("Updated: got %q", feed.Updated())
+	}
+	items := feed.Items()
+	newest := items[0]
+	if newest.Published() != "2018-10-08T17:04:11.000Z" || newest.Id() != "UgiMov1ngOn" {
+		t.Errorf("newest post: got %q published %q", newest.Id(), newest.Published())
+	}
+	if newest.Replies() != 2 {
+		t.Errorf("Replies: want 2, got %d", newest.Replies())
+	}
+	if as := newest.Attachments(); len(as) != 1 || !as[0].IsArticle() || as[0].URL() != "https://alice.example.com/" {
+		t.Errorf("want the blog link attached, got %+v", as)
+	}
+
+	photo := items[1]
+	if photo.Published() != "2018-07-05T03:15:00.000Z" {
+		t.Errorf("Published wasn't made UTC: %q", photo.Published())
+	}
+	as := photo.Attachments()
+	if len(as) != 1 || !as[0].IsPhoto() {
+		t.Fatalf("want a photo attached, got %+v", as)
+	}
+	img := as[0].FullImage()
+	if img.URL() != takeoutMediaBase+"Google+%20Stream/Posts/Fireworks.jpg" || img.Type() != "image/jpeg" || img.Width() != 1024 {
+		t.Errorf("photo: got %q of type %q, %d wide", img.URL(), img.Type(), img.Width())
+	}
+
+	reshare := items[2]
+	if !reshare.IsReshare() || reshare.Annotation() != "Bob is right about this." ||
+		reshare.Content() != "Tabs, not spaces." || reshare.OriginalActorName() != "Bob Example" {
+		t.Errorf("reshare: got %q annotating %q by %q", reshare.Annotation(), reshare.Content(), reshare.OriginalActorName())
+	}
+}
+
+func TestTakeoutHTML(t *testing.T) {
+	ts := loadTestTakeout(t)
+	feed, err := ts.Find("111111111111111111111")
+	if err != nil {
+		t.Fatalf("Find: %s", err)
+	}
+	items := feed.Items()
+	old := items[len(items)-1]
+	if old.Id() != "0ldP0st" || old.Published() != "2018-04-01T16:30:00.000Z" || old.ActorName() != "Alice Example" {
+		t.Errorf("HTML post: got %q by %q published %q", old.Id(), old.ActorName(), old.Published())
+	}
+	if old.ContentText() != "An old post, exported as HTML." || old.Replies() != 1 {
+		t.Errorf("HTML post: got %q with %d replies", old.ContentText(), old.Replies())
+	}
+	as := old.Attachments()
+	if len(as) != 1 || !as[0].IsPhoto() || as[0].FullImage().URL() != takeoutMediaBase+"Google+%20Stream/Posts/Fireworks.jpg" {
+		t.Errorf("want the photo attached, got %+v", as)
+	}
+
+	cs, err := ts.FindComments("0ldP0st")
+	if err != nil {
+		t.Fatalf("FindComments: %s", err)
+	}
+	if items := cs.Items(); len(items) != 1 || items[0].ActorName() != "Bob Example" || items[0].ContentText() != "Still a good one." {
+		t.Errorf("want Bob's comment, got %d comments", len(items))
+	}
+	if _, err := ts.FindComments("Fr1endsHTML"); !isNotFound(err) {
+		t.Errorf("friends-only HTML post: want not found, got %v", err)
+	}
+}
+
+func TestTakeoutFindPagesSince(t *testing.T) {
+	since, _ := ParseSince("2018-07-01")
+	feed, err := loadTestTakeout(t).FindPages("111111111111111111111", PageLimit{Pages: 5, Since: since})
+	if err != nil {
+		t.Fatalf("FindPages: %s", err)
+	}
+	if n := len(feed.Items()); n != 2 {
+		t.Errorf("want the 2 posts since July, got %d", n)
+	}
+}
+
+func TestTakeoutSearchAndComments(t *testing.T) {
+	ts := loadTestTakeout(t)
+	feed, err := ts.Search("tabs")
+	if err != nil {
+		t.Fatalf("Search: %s", err)
+	}
+	if len(feed.Items()) != 1 || feed.Items()[0].Id() != "UgiResh4re" {
+		t.Errorf("want the reshare found, got %d posts", len(feed.Items()))
+	}
+	feed, _ = ts.Search("only for my friends")
+	if len(feed.Items()) != 0 {
+		t.Errorf("found a post that isn't public")
+	}
+
+	feed, err = ts.FindComments("UgiMov1ngOn")
+	if err != nil {
+		t.Fatalf("FindComments: %s", err)
+	}
+	cs := feed.Items()
+	if len(cs) != 2 || cs[0].ActorName() != "Alice Example" || cs[1].ContentText() != "Sorry to see you go." {
+		t.Errorf("want 2 comments, newest first, got %d", len(cs))
+	}
+	if _, err := ts.FindComments("UgiFr1ends"); !isNotFound(err) {
+		t.Errorf("comments on a private post: want not found, got %v", err)
+	}
+}
+
+func TestTakeoutMedia(t *testing.T) {
+	h := http.StripPrefix("/takeout/", loadTestTakeout(t))
+	paths := map[string]int{
+		"/takeout/Google+%20Stream/Posts/Fireworks.jpg":                      http.StatusOK,
+		"/takeout/Google+%20Stream/Posts/Friends%20only.jpg":                 http.StatusNotFound,
+		"/takeout/Google+%20Stream/Posts/20180501%20-%20Friends%20only.json": http.StatusNotFound,
+		"/takeout/../plus2rss.go":                                            http.StatusNotFound,
+	}
+	for p, code := range paths {
+		r, _ := http.NewRequest("GET", p, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Errorf("%s: want %d, got %d", p, code, w.Code)
+		}
+	}
+}
+
+func TestTakeoutFrontend(t *testing.T) {
+	ts := loadTestTakeout(t)
+	h := NewFrontendMux(ts, ts, NewPlusSources(ts), NewCachingSearchStorage(ts, time.Minute, time.Minute, 10, 10), ts, nil, 10, FeedOptions{TitlePolicy: TitleDerived, TitleLength: 80}, testHost, "./templates")
+	for _, p := range []string{"/u/111111111111111111111", "/u/111111111111111111111.rss", "/u/111111111111111111111.json", "/c/UgiMov1ngOn", "/search?q=fireworks"} {
+		w := get(h, p, nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: want 200, got %d", p, w.Code)
+		}
+		if strings.Contains(w.Body.String(), "Only for my friends") {
+			t.Errorf("%s: served a post that isn't public", p)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The title policies. TitleFromAPI uses the title Google+ gives, which is
+// often empty or the start of the post cut off mid-word. TitleDerived makes
+// one from the first sentence of the post.
+const (
+	TitleFromAPI = "api"
+	TitleDerived = "derived"
+)
+
+func validTitlePolicy(policy string) bool {
+	return policy == TitleFromAPI || policy == TitleDerived
+}
+
+// entryTitle is the title of a with the given policy. Titles derived from
+// the post are at most maxLen characters long. Even TitleFromAPI derives
+// one when Google+ didn't give a title.
+func entryTitle(a Activity, policy string, maxLen int) string {
+	if policy == TitleFromAPI && strings.TrimSpace(a.Title()) != "" {
+		return a.Title()
+	}
+	return deriveTitle(a, maxLen)
+}
+
+// deriveTitle is the first sentence or line of a's text, cut at a word
+// boundary to at most maxLen characters. Posts without any text are named
+// after their first attachment, or what kind of thing it is.
+func deriveTitle(a Activity, maxLen int) string {
+	text := a.ContentText()
+	if a.IsReshare() {
+		if annotation := htmlText(a.Annotation()); annotation != "" {
+			text = annotation
+		}
+	}
+	if s := firstSentence(text); s != "" {
+		return truncateWords(s, maxLen)
+	}
+	if as := a.Attachments(); len(as) > 0 {
+		at := as[0]
+		if name := strings.TrimSpace(at.DisplayName()); name != "" {
+			return truncateWords(name, maxLen)
+		}
+		switch {
+		case at.IsPhoto():
+			return "Shared a photo"
+		case at.IsAlbum():
+			return "Shared an album"
+		case at.IsVideo():
+			return "Shared a video"
+		case at.IsAudio():
+			return "Shared some audio"
+		case at.IsEvent():
+			return "Shared an event"
+		case at.IsArticle():
+			return "Shared a link"
+		}
+	}
+	if a.IsReshare() {
+		return "Shared a post"
+	}
+	return "Untitled post"
+}
+
+// firstSentence is the first line of text, up to the end of its first
+// sentence.
+func firstSentence(text string) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	for i, r := range text {
+		if r != '.' && r != '!' && r != '?' {
+			continue
+		}
+		rest := text[i+1:]
+		if rest == "" || strings.HasPrefix(rest, " ") {
+			return strings.TrimSpace(text[:i+1])
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+// truncateWords cuts s down to at most maxLen characters, ending with an
+// ellipsis, at the last word boundary it can. maxLen of 0 or less leaves s
+// alone.
+func truncateWords(s string, maxLen int) string {
+	if maxLen <= 0 || utf8.RuneCountInString(s) <= maxLen {
+		return s
+	}
+	all := []rune(s)
+	runes := all[:maxLen-1]
+	cut := len(runes)
+	if !unicode.IsSpace(all[cut]) {
+		// The cut is mid-word, so back up to the last space, if any.
+		for i := len(runes) - 1; i > 0; i-- {
+			if unicode.IsSpace(runes[i]) {
+				cut = i
+				break
+			}
+		}
+	}
+	return strings.TrimRightFunc(string(runes[:cut]), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + "…"
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	plus "google.golang.org/api/plus/v1"
+)
+
+func titleActivity(title, content string, attachments ...*plus.ActivityObjectAttachments) Activity {
+	return &JSONActivity{plus.Activity{
+		Title:  title,
+		Verb:   "post",
+		Object: &plus.ActivityObject{Content: content, Attachments: attachments},
+	}}
+}
+
+func TestDeriveTitle(t *testing.T) {
+	tests := []struct {
+		a     Activity
+		title string
+	}{
+		{titleActivity("", "This is fascinating. The wikipedia article says so."), "This is fascinating."},
+		{titleActivity("", "First line<br />second line"), "First line"},
+		{titleActivity("", "Version 1.0 is out"), "Version 1.0 is out"},
+		{titleActivity("", "Is it? Yes!"), "Is it?"},
+		{titleActivity("", "a fairly long first sentence that has to be cut down to size"), "a fairly long first…"},
+		{titleActivity("", "<b>bold</b> &amp; <i>brash</i>"), "bold & brash"},
+		{titleActivity("", "", &plus.ActivityObjectAttachments{ObjectType: "article", DisplayName: "A link's title"}), "A link's title"},
+		{titleActivity("", "", &plus.ActivityObjectAttachments{ObjectType: "photo"}), "Shared a photo"},
+		{titleActivity("", "", &plus.ActivityObjectAttachments{ObjectType: "video"}), "Shared a video"},
+		{titleActivity("", ""), "Untitled post"},
+	}
+	for _, tt := range tests {
+		if title := deriveTitle(tt.a, 20); title != tt.title {
+			t.Errorf("%q: want %q, got %q", tt.a.Content(), tt.title, title)
+		}
+	}
+}
+
+func TestEntryTitlePolicies(t *testing.T) {
+	a := titleActivity("Quoting +Rob Pike: I am the person...", "Quoting +Rob Pike:<br />I am the person.")
+	if title := entryTitle(a, TitleFromAPI, 80); title != "Quoting +Rob Pike: I am the person..." {
+		t.Errorf("api: got %q", title)
+	}
+	if title := entryTitle(a, TitleDerived, 80); title != "Quoting +Rob Pike:" {
+		t.Errorf("derived: got %q", title)
+	}
+	a = titleActivity("", "", &plus.ActivityObjectAttachments{ObjectType: "photo"})
+	if title := entryTitle(a, TitleFromAPI, 80); title != "Shared a photo" {
+		t.Errorf("api without a title: got %q", title)
+	}
+}
+
+func TestTruncateWords(t *testing.T) {
+	tests := []struct {
+		s     string
+		max   int
+		trunc string
+	}{
+		{"short", 10, "short"},
+		{"exactly ten", 11, "exactly ten"},
+		{"one two three four", 12, "one two…"},
+		{"one, two, three", 12, "one, two…"},
+		{"unbrokenwordthatgoeson", 10, "unbrokenw…"},
+		{"ünïcödé wörds hére", 12, "ünïcödé…"},
+		{"anything", 0, "anything"},
+	}
+	for _, tt := range tests {
+		if trunc := truncateWords(tt.s, tt.max); trunc != tt.trunc {
+			t.Errorf("truncateWords(%q, %d): want %q, got %q", tt.s, tt.max, tt.trunc, trunc)
+		}
+	}
+}
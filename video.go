@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	youtubeIdR = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	vimeoIdR   = regexp.MustCompile(`^\d+$`)
+)
+
+// Video is a video attachment sorted out by where it lives. Google+ embeds
+// videos with flash players that nothing plays anymore, so feeds link to
+// the video's page or play its file with an HTML5 <video> instead.
+type Video struct {
+	Attachment
+	Host     string // "youtube", "vimeo" or "" for anywhere else
+	WatchURL string // the page to watch the video on
+	FileURL  string // the video file itself, if it's linked directly
+	FileType string
+	Poster   string // an image to show in the video's place, if any
+}
+
+// NewVideo works out where the video attachment a is from, using its embed
+// and then its URL.
+func NewVideo(a Attachment) *Video {
+	v := &Video{Attachment: a, WatchURL: a.URL()}
+	if i := a.Image(); i != nil {
+		v.Poster = i.URL()
+	}
+	var embedURL, embedType string
+	if e := a.Embed(); e != nil {
+		embedURL, embedType = e.URL(), e.Type()
+	}
+
+	for _, raw := range []string{embedURL, a.URL()} {
+		u, err := url.Parse(raw)
+		if raw == "" || err != nil {
+			continue
+		}
+		if id := youtubeId(u); id != "" {
+			v.Host = "youtube"
+			v.WatchURL = "https://www.youtube.com/watch?v=" + id
+			if v.Poster == "" {
+				v.Poster = "https://i.ytimg.com/vi/" + id + "/hqdefault.jpg"
+			}
+			return v
+		}
+		if id := vimeoId(u); id != "" {
+			v.Host = "vimeo"
+			v.WatchURL = "https://vimeo.com/" + id
+			return v
+		}
+	}
+
+	if embedURL != "" && isVideoFile(embedURL, embedType) {
+		v.FileURL = embedURL
+		v.FileType = mimeType(embedURL, videoType(embedType), "video/mp4")
+	} else if isVideoFile(a.URL(), "") {
+		v.FileURL = a.URL()
+		v.FileType = mimeType(a.URL(), "", "video/mp4")
+	}
+	if v.WatchURL == "" {
+		v.WatchURL = v.FileURL
+	}
+	return v
+}
+
+// youtubeId finds the video id in the many shapes of YouTube URL: watch
+// pages, youtu.be links, and the /v/ and /embed/ players. The old /v/
+// player URLs Google+ hands out put their parameters after an "&" in the
+// path.
+func youtubeId(u *url.URL) string {
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	host = strings.TrimPrefix(host, "m.")
+	var id string
+	switch host {
+	case "youtu.be":
+		id = strings.TrimPrefix(u.Path, "/")
+	case "youtube.com", "youtube-nocookie.com":
+		switch {
+		case u.Path == "/watch":
+			id = u.Query().Get("v")
+		case strings.HasPrefix(u.Path, "/v/"):
+			id = strings.TrimPrefix(u.Path, "/v/")
+		case strings.HasPrefix(u.Path, "/embed/"):
+			id = strings.TrimPrefix(u.Path, "/embed/")
+		}
+	}
+	if i := strings.IndexAny(id, "&/"); i >= 0 {
+		id = id[:i]
+	}
+	if !youtubeIdR.MatchString(id) {
+		return ""
+	}
+	return id
+}
+
+// vimeoId finds the video id in vimeo.com pages, the player.vimeo.com
+// player and the old moogaloop flash player.
+func vimeoId(u *url.URL) string {
+	var id string
+	switch strings.TrimPrefix(strings.ToLower(u.Host), "www.") {
+	case "vimeo.com":
+		if u.Path == "/moogaloop.swf" {
+			id = u.Query().Get("clip_id")
+		} else {
+			id = strings.TrimPrefix(u.Path, "/")
+		}
+	case "player.vimeo.com":
+		id = strings.TrimPrefix(u.Path, "/video/")
+	}
+	if !vimeoIdR.MatchString(id) {
+		return ""
+	}
+	return id
+}
+
+// isVideoFile reports whether rawurl is a video a <video> element can play,
+// going by typ and then the URL's extension.
+func isVideoFile(rawurl, typ string) bool {
+	if videoType(typ) != "" {
+		return true
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".mp4", ".m4v", ".webm", ".ogv":
+		return true
+	}
+	return false
+}
+
+// videoType is typ if it's a type browsers play, and "" otherwise. Flash,
+// in particular, is out.
+func videoType(typ string) string {
+	switch typ {
+	case "video/mp4", "video/webm", "video/ogg":
+		return typ
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	plus "google.golang.org/api/plus/v1"
+)
+
+func TestNewVideo(t *testing.T) {
+	tests := []struct {
+		url, embedURL, embedType string
+		host, watch, file, typ   string
+	}{
+		{"http://www.youtube.com/watch?v=wrxdWkjmhKg", "http://www.youtube.com/v/wrxdWkjmhKg&hl=en&fs=1&autoplay=1", "application/x-shockwave-flash",
+			"youtube", "https://www.youtube.com/watch?v=wrxdWkjmhKg", "", ""},
+		{"https://youtu.be/wrxdWkjmhKg", "", "",
+			"youtube", "https://www.youtube.com/watch?v=wrxdWkjmhKg", "", ""},
+		{"https://example.com/post", "https://www.youtube.com/embed/wrxdWkjmhKg?rel=0", "text/html",
+			"youtube", "https://www.youtube.com/watch?v=wrxdWkjmhKg", "", ""},
+		{"http://vimeo.com/1084537", "http://vimeo.com/moogaloop.swf?clip_id=1084537&autoplay=1", "application/x-shockwave-flash",
+			"vimeo", "https://vimeo.com/1084537", "", ""},
+		{"https://example.com/post", "https://player.vimeo.com/video/1084537", "text/html",
+			"vimeo", "https://vimeo.com/1084537", "", ""},
+		{"https://example.com/post", "https://example.com/movie", "video/mp4",
+			"", "https://example.com/post", "https://example.com/movie", "video/mp4"},
+		{"https://example.com/movie.mp4", "", "",
+			"", "https://example.com/movie.mp4", "https://example.com/movie.mp4", "video/mp4"},
+		{"https://example.com/movie.swf", "https://example.com/movie.swf", "application/x-shockwave-flash",
+			"", "https://example.com/movie.swf", "", ""},
+	}
+	for _, tt := range tests {
+		pa := plus.ActivityObjectAttachments{ObjectType: "video", Url: tt.url}
+		if tt.embedURL != "" {
+			pa.Embed = &plus.ActivityObjectAttachmentsEmbed{Url: tt.embedURL, Type: tt.embedType}
+		}
+		v := NewVideo(&JSONAttachment{pa})
+		if v.Host != tt.host || v.WatchURL != tt.watch || v.FileURL != tt.file || v.FileType != tt.typ {
+			t.Errorf("%s %s: got host %q, watch %q, file %q %q", tt.url, tt.embedURL, v.Host, v.WatchURL, v.FileURL, v.FileType)
+		}
+	}
+}
+
+func TestVideoPoster(t *testing.T) {
+	pa := plus.ActivityObjectAttachments{ObjectType: "video", Url: "https://youtu.be/wrxdWkjmhKg"}
+	if p := NewVideo(&JSONAttachment{pa}).Poster; p != "https://i.ytimg.com/vi/wrxdWkjmhKg/hqdefault.jpg" {
+		t.Errorf("YouTube poster: got %q", p)
+	}
+	pa.Image = &plus.ActivityObjectAttachmentsImage{Url: "https://example.com/still.jpg"}
+	if p := NewVideo(&JSONAttachment{pa}).Poster; p != "https://example.com/still.jpg" {
+		t.Errorf("poster from the attachment's image: got %q", p)
+	}
+}